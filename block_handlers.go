@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// viewerFromRequest returns the user making the request when it carries a
+// token. Reading chirps doesn't need one, but a bad one is still rejected.
+func (cfg *apiConfig) viewerFromRequest(r *http.Request) (uuid.NullUUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.NullUUID{}, nil
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeChirpsRead)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}, nil
+}
+
+// filteredAuthors returns the authors whose chirps viewer shouldn't see in
+// timelines: anyone blocking or blocked by them, and anyone they muted. Every
+// listing of chirps goes through this.
+func (cfg *apiConfig) filteredAuthors(ctx context.Context, viewer uuid.NullUUID) (map[uuid.UUID]bool, error) {
+	filtered := map[uuid.UUID]bool{}
+	if !viewer.Valid {
+		return filtered, nil
+	}
+
+	ids, err := cfg.db.GetFilteredAuthorsForUser(ctx, viewer.UUID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		filtered[id] = true
+	}
+	return filtered, nil
+}
+
+// blockedBetween reports whether either user blocked the other. Use it
+// wherever one user acts on another, not just when listing chirps.
+func (cfg *apiConfig) blockedBetween(ctx context.Context, a, b uuid.UUID) (bool, error) {
+	hasBlockBetweenArgs := database.HasBlockBetweenParams{
+		BlockerID: a,
+		BlockedID: b,
+	}
+	return cfg.db.HasBlockBetween(ctx, hasBlockBetweenArgs)
+}
+
+type relationshipInfo struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// relationshipTarget authenticates the request and reads the other user from
+// the request body (POST) or the path (DELETE).
+func (cfg *apiConfig) relationshipTarget(w http.ResponseWriter, r *http.Request) (userID, targetID uuid.UUID, ok bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	userID, err = auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	if r.Method == http.MethodPost {
+		type requestVals struct {
+			UserID uuid.UUID `json:"user_id"`
+		}
+
+		defer r.Body.Close()
+
+		decoder := json.NewDecoder(r.Body)
+		params := requestVals{}
+		if err := decoder.Decode(&params); err != nil {
+			returnError(w, err, 400)
+			return uuid.UUID{}, uuid.UUID{}, false
+		}
+		targetID = params.UserID
+
+		if _, err := cfg.db.GetUser(r.Context(), targetID); err != nil {
+			returnError(w, fmt.Errorf("user not found"), 404)
+			return uuid.UUID{}, uuid.UUID{}, false
+		}
+	} else {
+		targetID, err = uuid.Parse(r.PathValue("userID"))
+		if err != nil {
+			returnError(w, err, 404)
+			return uuid.UUID{}, uuid.UUID{}, false
+		}
+	}
+
+	if targetID == userID {
+		returnError(w, fmt.Errorf("cannot do that to yourself"), 400)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+	return userID, targetID, true
+}
+
+func writeRelationships(w http.ResponseWriter, responseData []relationshipInfo) {
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerPostBlocks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	createBlockArgs := database.CreateBlockParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	}
+	if err := qtx.CreateBlock(r.Context(), createBlockArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	// A block ends any following in either direction.
+	deleteFollowsBetweenArgs := database.DeleteFollowsBetweenParams{
+		FollowerID: userID,
+		FollowedID: targetID,
+	}
+	if err := qtx.DeleteFollowsBetween(r.Context(), deleteFollowsBetweenArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerDeleteBlock(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	deleteBlockArgs := database.DeleteBlockParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	}
+	if err := cfg.db.DeleteBlock(r.Context(), deleteBlockArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetBlocks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	blocks, err := cfg.db.GetBlocksForUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	responseData := []relationshipInfo{}
+	for _, b := range blocks {
+		responseData = append(responseData, relationshipInfo{UserID: b.BlockedID, CreatedAt: b.CreatedAt})
+	}
+	writeRelationships(w, responseData)
+}
+
+func (cfg *apiConfig) handlerPostMutes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	createMuteArgs := database.CreateMuteParams{
+		MuterID: userID,
+		MutedID: targetID,
+	}
+	if err := cfg.db.CreateMute(r.Context(), createMuteArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerDeleteMute(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	deleteMuteArgs := database.DeleteMuteParams{
+		MuterID: userID,
+		MutedID: targetID,
+	}
+	if err := cfg.db.DeleteMute(r.Context(), deleteMuteArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetMutes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	mutes, err := cfg.db.GetMutesForUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	responseData := []relationshipInfo{}
+	for _, m := range mutes {
+		responseData = append(responseData, relationshipInfo{UserID: m.MutedID, CreatedAt: m.CreatedAt})
+	}
+	writeRelationships(w, responseData)
+}
+
+func (cfg *apiConfig) handlerPostFollows(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	blocked, err := cfg.blockedBetween(r.Context(), userID, targetID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if blocked {
+		returnError(w, fmt.Errorf("cannot follow this user"), 403)
+		return
+	}
+
+	createFollowArgs := database.CreateFollowParams{
+		FollowerID: userID,
+		FollowedID: targetID,
+	}
+	rows, err := cfg.db.CreateFollow(r.Context(), createFollowArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	// Following someone again isn't news to them.
+	if rows > 0 {
+		if err := cfg.notify(r.Context(), cfg.db, targetID, userID, notificationFollow, uuid.NullUUID{}); err != nil {
+			returnError(w, err, 500)
+			return
+		}
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerDeleteFollow(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	deleteFollowArgs := database.DeleteFollowParams{
+		FollowerID: userID,
+		FollowedID: targetID,
+	}
+	if err := cfg.db.DeleteFollow(r.Context(), deleteFollowArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetFollows(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	follows, err := cfg.db.GetFollowing(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	responseData := []relationshipInfo{}
+	for _, f := range follows {
+		responseData = append(responseData, relationshipInfo{UserID: f.FollowedID, CreatedAt: f.CreatedAt})
+	}
+	writeRelationships(w, responseData)
+}
+
+func (cfg *apiConfig) handlerGetFollowers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	follows, err := cfg.db.GetFollowers(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	responseData := []relationshipInfo{}
+	for _, f := range follows {
+		responseData = append(responseData, relationshipInfo{UserID: f.FollowerID, CreatedAt: f.CreatedAt})
+	}
+	writeRelationships(w, responseData)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type savedChirpInfo struct {
+	chirpInfo
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// savedChirpsPage turns one more row than limit of saved chirps into a page
+// ordered by save time.
+func savedChirpsPage(ctx context.Context, q *database.Queries, viewer uuid.NullUUID, chirps []database.Chirp, savedAt []time.Time, limit int) (page[savedChirpInfo], error) {
+	infos, err := chirpInfos(ctx, q, viewer, chirps)
+	if err != nil {
+		return page[savedChirpInfo]{}, err
+	}
+
+	items := make([]savedChirpInfo, 0, len(infos))
+	for i, info := range infos {
+		items = append(items, savedChirpInfo{chirpInfo: info, SavedAt: savedAt[i]})
+	}
+	return newPage(items, limit, func(s savedChirpInfo) pageCursor {
+		return pageCursor{Time: s.SavedAt, ID: s.ID}
+	}), nil
+}
+
+func writePage[T any](w http.ResponseWriter, p page[T]) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerPostBookmark(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	chirp, _, ok := cfg.visibleChirp(w, r)
+	if !ok {
+		return
+	}
+
+	createBookmarkArgs := database.CreateBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	}
+	if err := cfg.db.CreateBookmark(r.Context(), createBookmarkArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerDeleteBookmark(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	// No visibility check: a bookmark on a chirp that has since been hidden
+	// or blocked can still be removed.
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	deleteBookmarkArgs := database.DeleteBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	}
+	if _, err := qtx.DeleteBookmark(r.Context(), deleteBookmarkArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	// Collections are folders of bookmarks, so the chirp leaves them too.
+	removeChirpFromUserCollectionsArgs := database.RemoveChirpFromUserCollectionsParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	}
+	if err := qtx.RemoveChirpFromUserCollections(r.Context(), removeChirpFromUserCollectionsArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetBookmarks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	getBookmarkedChirpsArgs := database.GetBookmarkedChirpsParams{
+		UserID:     userID,
+		BeforeTime: cursor.Time,
+		BeforeID:   cursor.ID,
+		PageSize:   int32(limit + 1),
+	}
+	rows, err := cfg.db.GetBookmarkedChirps(r.Context(), getBookmarkedChirpsArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	chirps := make([]database.Chirp, 0, len(rows))
+	savedAt := make([]time.Time, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, database.Chirp{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body:      row.Body,
+			UserID:    row.UserID,
+			HiddenAt:  row.HiddenAt,
+			DeletedAt: row.DeletedAt,
+			EditedAt:  row.EditedAt,
+		})
+		savedAt = append(savedAt, row.SavedAt)
+	}
+
+	p, err := savedChirpsPage(r.Context(), cfg.db, uuid.NullUUID{UUID: userID, Valid: true}, chirps, savedAt, limit)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	writePage(w, p)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/entitlements"
+	"github.com/MagnusTrier/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerPatchChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeChirpsWrite)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	type requestVals struct {
+		Body string `json:"body"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if isSuspended(user) {
+		returnSuspended(w, user)
+		return
+	}
+
+	plan, err := cfg.planFor(r.Context(), user)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if !cfg.requireEntitlement(w, plan, entitlements.EditChirps) {
+		return
+	}
+
+	// Edits go through the same checks as new chirps, so an edit can't be
+	// used to sneak content past moderation.
+	moderated, ok := cfg.checkChirpBody(w, plan, params.Body)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Locking the chirp keeps concurrent edits from both recording the same
+	// previous body as their revision.
+	chirp, err := qtx.LockChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		returnError(w, fmt.Errorf("chirp not found"), 404)
+		return
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if chirp.UserID != userID {
+		returnError(w, fmt.Errorf("cannot edit other peoples chirps"), 403)
+		return
+	}
+	if chirp.HiddenAt.Valid {
+		returnError(w, fmt.Errorf("chirp was hidden by a moderator"), 409)
+		return
+	}
+	if time.Since(chirp.CreatedAt) > cfg.editWindow {
+		returnError(w, fmt.Errorf("chirps can only be edited for %v after posting", cfg.editWindow), 409)
+		return
+	}
+
+	edited := moderated.Text != chirp.Body
+	if edited {
+		createChirpRevisionArgs := database.CreateChirpRevisionParams{
+			ChirpID: chirp.ID,
+			Body:    chirp.Body,
+		}
+		if err := qtx.CreateChirpRevision(r.Context(), createChirpRevisionArgs); err != nil {
+			returnError(w, err, 500)
+			return
+		}
+
+		updateChirpBodyArgs := database.UpdateChirpBodyParams{
+			ID:   chirp.ID,
+			Body: moderated.Text,
+		}
+		chirp, err = qtx.UpdateChirpBody(r.Context(), updateChirpBodyArgs)
+		if err != nil {
+			returnError(w, err, 500)
+			return
+		}
+
+		if err := recordModerationFlags(r.Context(), qtx, chirp.ID, moderated); err != nil {
+			returnError(w, err, 500)
+			return
+		}
+
+		if err := enqueueOutboxEvent(r.Context(), qtx, webhooks.EventChirpUpdated, chirp.UserID, newChirpInfo(chirp)); err != nil {
+			returnError(w, err, 500)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if edited {
+		cfg.publishChirp(r.Context(), eventChirpEdited, chirp)
+	}
+
+	info, err := chirpInfoFor(r.Context(), cfg.db, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerGetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	chirp, _, ok := cfg.visibleChirp(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := cfg.db.GetChirpRevisions(r.Context(), chirp.ID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	// Each revision is a body the chirp had until replaced_at.
+	type revisionInfo struct {
+		ID         uuid.UUID `json:"id"`
+		Body       string    `json:"body"`
+		ReplacedAt time.Time `json:"replaced_at"`
+	}
+
+	responseData := []revisionInfo{}
+	for _, rev := range revisions {
+		responseData = append(responseData, revisionInfo{
+			ID:         rev.ID,
+			Body:       rev.Body,
+			ReplacedAt: rev.CreatedAt,
+		})
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
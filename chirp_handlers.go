@@ -1,27 +1,149 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"sort"
-	"strings"
+	"time"
 
 	"github.com/MagnusTrier/chirpy/internal/auth"
 	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/entitlements"
+	"github.com/MagnusTrier/chirpy/internal/moderation"
+	"github.com/MagnusTrier/chirpy/internal/webhooks"
 	"github.com/google/uuid"
 )
 
+type chirpInfo struct {
+	ID          uuid.UUID        `json:"id"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	Body        string           `json:"body"`
+	UserID      uuid.UUID        `json:"user_id"`
+	EditedAt    *time.Time       `json:"edited_at"`
+	Attachments []attachmentInfo `json:"attachments"`
+	Poll        *pollInfo        `json:"poll"`
+	// Bookmarked is only filled in for a signed-in viewer.
+	Bookmarked *bool `json:"bookmarked"`
+}
+
+func newChirpInfo(chirp database.Chirp) chirpInfo {
+	info := chirpInfo{
+		ID:          chirp.ID,
+		CreatedAt:   chirp.CreatedAt,
+		UpdatedAt:   chirp.UpdatedAt,
+		Body:        chirp.Body,
+		UserID:      chirp.UserID,
+		Attachments: []attachmentInfo{},
+	}
+	if chirp.EditedAt.Valid {
+		info.EditedAt = &chirp.EditedAt.Time
+	}
+	return info
+}
+
+// chirpInfos builds the JSON for chirps as viewer sees them, loading
+// attachments, polls and bookmarks for the whole slice at once.
+func chirpInfos(ctx context.Context, q *database.Queries, viewer uuid.NullUUID, chirps []database.Chirp) ([]chirpInfo, error) {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+
+	attachments, err := attachmentInfosForChirps(ctx, q, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	polls, err := pollInfosForChirps(ctx, q, viewer, chirps)
+	if err != nil {
+		return nil, err
+	}
+
+	var bookmarked map[uuid.UUID]bool
+	if viewer.Valid {
+		getBookmarkedChirpIDsArgs := database.GetBookmarkedChirpIDsParams{
+			UserID:   viewer.UUID,
+			ChirpIds: ids,
+		}
+		bookmarkedIDs, err := q.GetBookmarkedChirpIDs(ctx, getBookmarkedChirpIDsArgs)
+		if err != nil {
+			return nil, err
+		}
+		bookmarked = map[uuid.UUID]bool{}
+		for _, id := range bookmarkedIDs {
+			bookmarked[id] = true
+		}
+	}
+
+	infos := make([]chirpInfo, 0, len(chirps))
+	for _, chirp := range chirps {
+		info := newChirpInfo(chirp)
+		if a, ok := attachments[chirp.ID]; ok {
+			info.Attachments = a
+		}
+		info.Poll = polls[chirp.ID]
+		if bookmarked != nil {
+			b := bookmarked[chirp.ID]
+			info.Bookmarked = &b
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func chirpInfoFor(ctx context.Context, q *database.Queries, viewer uuid.NullUUID, chirp database.Chirp) (chirpInfo, error) {
+	infos, err := chirpInfos(ctx, q, viewer, []database.Chirp{chirp})
+	if err != nil {
+		return chirpInfo{}, err
+	}
+	return infos[0], nil
+}
+
+// checkChirpBody applies the plan's length limit and the moderation rules to a
+// new chirp body. It writes the error response and returns false when the body
+// can't be used.
+func (cfg *apiConfig) checkChirpBody(w http.ResponseWriter, plan entitlements.Plan, body string) (moderation.Result, bool) {
+	if len(body) > plan.MaxChirpLength && len(body) <= cfg.entitlements.LongestChirp() {
+		cfg.returnMissingEntitlement(w, entitlements.MaxChirpLength)
+		return moderation.Result{}, false
+	}
+
+	if len(body) > plan.MaxChirpLength {
+		type returnValsError struct {
+			Msg string `json:"error"`
+		}
+
+		w.WriteHeader(400)
+		data, err := json.Marshal(returnValsError{Msg: "chirp is too long"})
+		if err != nil {
+			fmt.Print(err)
+			return moderation.Result{}, false
+		}
+		w.Write(data)
+		return moderation.Result{}, false
+	}
+
+	moderated := cfg.moderator.Check(body)
+	if moderated.Rejected() {
+		returnModerationRejected(w, moderated)
+		return moderation.Result{}, false
+	}
+	return moderated, true
+}
+
 func (cfg *apiConfig) handlerPostChirps(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	type requestVals struct {
-		Body   string    `json:"body"`
-		UserID uuid.UUID `json:"user_id"`
-	}
-
-	type returnValsError struct {
-		Msg string `json:"error"`
+		Body          string       `json:"body"`
+		UserID        uuid.UUID    `json:"user_id"`
+		PublishAt     *time.Time   `json:"publish_at"`
+		AttachmentIDs []uuid.UUID  `json:"attachment_ids"`
+		Poll          *pollRequest `json:"poll"`
 	}
 
 	token, err := auth.GetBearerToken(r.Header)
@@ -30,7 +152,7 @@ func (cfg *apiConfig) handlerPostChirps(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeChirpsWrite)
 	if err != nil {
 		returnError(w, err, 401)
 		return
@@ -45,41 +167,135 @@ func (cfg *apiConfig) handlerPostChirps(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if _, err := cfg.db.GetUser(r.Context(), userID); err != nil {
+	user, err := cfg.db.GetUser(r.Context(), userID)
+	if err != nil {
 		returnError(w, err, 500)
 		return
 	}
+	if isSuspended(user) {
+		returnSuspended(w, user)
+		return
+	}
 
-	if len(params.Body) > 140 {
-		w.WriteHeader(400)
-		data, err := json.Marshal(returnValsError{Msg: "chirp is too long"})
-		if err != nil {
-			w.WriteHeader(500)
-			fmt.Print(err)
+	plan, err := cfg.planFor(r.Context(), user)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	attachmentIDs := []uuid.UUID{}
+	for _, id := range params.AttachmentIDs {
+		if !slices.Contains(attachmentIDs, id) {
+			attachmentIDs = append(attachmentIDs, id)
+		}
+	}
+	if len(attachmentIDs) > maxAttachmentsPerChirp {
+		returnError(w, fmt.Errorf("a chirp can have at most %d attachments", maxAttachmentsPerChirp), 400)
+		return
+	}
+	if (len(attachmentIDs) > 0 || params.Poll != nil) && params.PublishAt != nil {
+		returnError(w, fmt.Errorf("scheduled chirps can't have attachments or polls"), 400)
+		return
+	}
+
+	if params.PublishAt != nil {
+		if !cfg.requireEntitlement(w, plan, entitlements.ScheduleChirps) {
 			return
 		}
-		w.Write(data)
+		if err := validatePublishAt(*params.PublishAt); err != nil {
+			returnError(w, err, 400)
+			return
+		}
+	}
+
+	moderated, ok := cfg.checkChirpBody(w, plan, params.Body)
+	if !ok {
 		return
 	}
 
-	notAllowed := []string{"kerfuffle", "sharbert", "fornax", "Kerfuffle", "Sharbert", "Fornax"}
-	cleanedChirp := params.Body
+	var pollOptions []string
+	if params.Poll != nil {
+		pollOptions, ok = cfg.checkPoll(w, params.Poll)
+		if !ok {
+			return
+		}
+	}
 
-	for _, w := range notAllowed {
-		cleanedChirp = strings.ReplaceAll(cleanedChirp, w, "****")
+	if ok, retryAfter := cfg.chirpLimiter.Allow(userID.String(), plan.ChirpsPerHour); !ok {
+		returnRateLimited(w, retryAfter)
+		return
 	}
 
+	if params.PublishAt != nil {
+		cfg.scheduleChirp(w, r, userID, moderated.Text, *params.PublishAt)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
 	chirpArgs := database.CreateChirpParams{
-		Body:   cleanedChirp,
+		Body:   moderated.Text,
 		UserID: userID,
 	}
-	chirp, err := cfg.db.CreateChirp(r.Context(), chirpArgs)
+	chirp, err := qtx.CreateChirp(r.Context(), chirpArgs)
 	if err != nil {
 		returnError(w, err, 500)
 		return
 	}
 
-	data, err := json.Marshal(chirp)
+	if len(attachmentIDs) > 0 {
+		attachToChirpArgs := database.AttachToChirpParams{
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Ids:     attachmentIDs,
+			UserID:  userID,
+		}
+		n, err := qtx.AttachToChirp(r.Context(), attachToChirpArgs)
+		if err != nil {
+			returnError(w, err, 500)
+			return
+		}
+		if n != int64(len(attachmentIDs)) {
+			returnError(w, fmt.Errorf("attachments must be your own unused uploads"), 400)
+			return
+		}
+	}
+
+	if params.Poll != nil {
+		if err := createPoll(r.Context(), qtx, chirp.ID, *params.Poll, pollOptions); err != nil {
+			returnError(w, err, 500)
+			return
+		}
+	}
+
+	if err := recordModerationFlags(r.Context(), qtx, chirp.ID, moderated); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	info, err := chirpInfoFor(r.Context(), qtx, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := enqueueOutboxEvent(r.Context(), qtx, webhooks.EventChirpCreated, chirp.UserID, info); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	cfg.publishChirp(r.Context(), eventChirp, chirp)
+
+	data, err := json.Marshal(info)
 	if err != nil {
 		w.WriteHeader(500)
 		fmt.Print(err)
@@ -93,15 +309,20 @@ func (cfg *apiConfig) handlerPostChirps(w http.ResponseWriter, r *http.Request)
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	viewer, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
 	authorString := r.URL.Query().Get("author_id")
 
 	var chirps []database.Chirp
-	var err error
 
 	if authorString != "" {
-		authorID, err := uuid.Parse(authorString)
-		if err != nil {
-			returnError(w, err, 500)
+		authorID, parseErr := uuid.Parse(authorString)
+		if parseErr != nil {
+			returnError(w, parseErr, 500)
 			return
 		}
 		chirps, err = cfg.db.GetAllChirpsFromUser(r.Context(), authorID)
@@ -114,11 +335,24 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filtered, err := cfg.filteredAuthors(r.Context(), viewer)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	chirps = slices.DeleteFunc(chirps, func(c database.Chirp) bool { return filtered[c.UserID] })
+
 	if order := r.URL.Query().Get("sort"); order == "desc" {
 		sort.Slice(chirps, func(i, j int) bool { return chirps[i].CreatedAt.After(chirps[j].CreatedAt) })
 	}
 
-	data, err := json.Marshal(chirps)
+	responseData, err := chirpInfos(r.Context(), cfg.db, viewer, chirps)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(responseData)
 	if err != nil {
 		w.WriteHeader(500)
 		fmt.Print(err)
@@ -129,23 +363,63 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
-func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// visibleChirp loads the chirp named in the path if the requesting user may
+// see it, and writes a 404 otherwise.
+func (cfg *apiConfig) visibleChirp(w http.ResponseWriter, r *http.Request) (database.Chirp, uuid.NullUUID, bool) {
+	viewer, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		returnError(w, err, 401)
+		return database.Chirp{}, uuid.NullUUID{}, false
+	}
 
 	chirpIDString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIDString)
 	if err != nil {
 		returnError(w, err, 404)
-		return
+		return database.Chirp{}, uuid.NullUUID{}, false
 	}
 
 	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
 	if err != nil {
 		returnError(w, err, 404)
+		return database.Chirp{}, uuid.NullUUID{}, false
+	}
+	if chirp.HiddenAt.Valid {
+		returnError(w, fmt.Errorf("chirp not found"), 404)
+		return database.Chirp{}, uuid.NullUUID{}, false
+	}
+
+	// Mutes only apply to timelines, but blocks hide chirps everywhere.
+	if viewer.Valid {
+		blocked, err := cfg.blockedBetween(r.Context(), viewer.UUID, chirp.UserID)
+		if err != nil {
+			returnError(w, err, 500)
+			return database.Chirp{}, uuid.NullUUID{}, false
+		}
+		if blocked {
+			returnError(w, fmt.Errorf("chirp not found"), 404)
+			return database.Chirp{}, uuid.NullUUID{}, false
+		}
+	}
+
+	return chirp, viewer, true
+}
+
+func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	chirp, viewer, ok := cfg.visibleChirp(w, r)
+	if !ok {
 		return
 	}
 
-	data, err := json.Marshal(chirp)
+	info, err := chirpInfoFor(r.Context(), cfg.db, viewer, chirp)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(info)
 	if err != nil {
 		w.WriteHeader(500)
 		fmt.Print(err)
@@ -163,7 +437,7 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeChirpsWrite)
 	if err != nil {
 		returnError(w, err, 401)
 		return
@@ -186,9 +460,34 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := cfg.db.DeleteChirp(r.Context(), chirpID); err != nil {
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Deleting only moves the chirp to the trash. The purge job removes it
+	// for good once trashRetention has passed.
+	if err := qtx.SoftDeleteChirp(r.Context(), chirpID); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if err := qtx.RotateVariantURLKeys(r.Context(), chirpID); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := enqueueOutboxEvent(r.Context(), qtx, webhooks.EventChirpDeleted, chirp.UserID, newChirpInfo(chirp)); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
 		returnError(w, err, 500)
 		return
 	}
+	cfg.publishEvent(chirpTopics(chirp), eventChirpDeleted, streamChirpDeleted{ID: chirp.ID, UserID: chirp.UserID})
 	w.WriteHeader(204)
 }
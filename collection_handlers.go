@@ -0,0 +1,465 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxCollectionsPerUser        = 100
+	maxCollectionNameLength      = 50
+	maxCollectionDescriptionSize = 200
+)
+
+type collectionInfo struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UserID      uuid.UUID `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Private     bool      `json:"private"`
+}
+
+func newCollectionInfo(c database.Collection) collectionInfo {
+	return collectionInfo{
+		ID:          c.ID,
+		CreatedAt:   c.CreatedAt,
+		UpdatedAt:   c.UpdatedAt,
+		UserID:      c.UserID,
+		Name:        c.Name,
+		Description: c.Description,
+		Private:     c.IsPrivate,
+	}
+}
+
+func validateCollection(name, description string) error {
+	if name == "" || len(name) > maxCollectionNameLength {
+		return fmt.Errorf("name must be between 1 and %d characters", maxCollectionNameLength)
+	}
+	if len(description) > maxCollectionDescriptionSize {
+		return fmt.Errorf("description is limited to %d characters", maxCollectionDescriptionSize)
+	}
+	return nil
+}
+
+func writeCollection(w http.ResponseWriter, collection database.Collection, code int) {
+	data, err := json.Marshal(newCollectionInfo(collection))
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// ownCollection authenticates the request and loads the collection in the
+// path, which must belong to the caller.
+func (cfg *apiConfig) ownCollection(w http.ResponseWriter, r *http.Request) (database.Collection, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return database.Collection{}, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return database.Collection{}, false
+	}
+
+	collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return database.Collection{}, false
+	}
+
+	collection, err := cfg.db.GetCollection(r.Context(), collectionID)
+	if err != nil || collection.UserID != userID {
+		returnError(w, fmt.Errorf("collection not found"), 404)
+		return database.Collection{}, false
+	}
+	return collection, true
+}
+
+// visibleCollection loads the collection in the path for any viewer allowed
+// to see it: its owner, or anyone not blocked when it's public.
+func (cfg *apiConfig) visibleCollection(w http.ResponseWriter, r *http.Request) (database.Collection, uuid.NullUUID, bool) {
+	viewer, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		returnError(w, err, 401)
+		return database.Collection{}, uuid.NullUUID{}, false
+	}
+
+	collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return database.Collection{}, uuid.NullUUID{}, false
+	}
+
+	collection, err := cfg.db.GetCollection(r.Context(), collectionID)
+	if err != nil {
+		returnError(w, fmt.Errorf("collection not found"), 404)
+		return database.Collection{}, uuid.NullUUID{}, false
+	}
+
+	if viewer.Valid && viewer.UUID == collection.UserID {
+		return collection, viewer, true
+	}
+	if collection.IsPrivate {
+		returnError(w, fmt.Errorf("collection not found"), 404)
+		return database.Collection{}, uuid.NullUUID{}, false
+	}
+	if viewer.Valid {
+		blocked, err := cfg.blockedBetween(r.Context(), viewer.UUID, collection.UserID)
+		if err != nil {
+			returnError(w, err, 500)
+			return database.Collection{}, uuid.NullUUID{}, false
+		}
+		if blocked {
+			returnError(w, fmt.Errorf("collection not found"), 404)
+			return database.Collection{}, uuid.NullUUID{}, false
+		}
+	}
+	return collection, viewer, true
+}
+
+func (cfg *apiConfig) handlerPostCollections(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	type requestVals struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Private     *bool  `json:"private"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	name := strings.TrimSpace(params.Name)
+	if err := validateCollection(name, params.Description); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	count, err := cfg.db.CountCollectionsForUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if count >= maxCollectionsPerUser {
+		returnError(w, fmt.Errorf("you can have at most %d collections", maxCollectionsPerUser), 400)
+		return
+	}
+
+	createCollectionArgs := database.CreateCollectionParams{
+		UserID:      userID,
+		Name:        name,
+		Description: params.Description,
+		IsPrivate:   params.Private == nil || *params.Private,
+	}
+	collection, err := cfg.db.CreateCollection(r.Context(), createCollectionArgs)
+	if errors.Is(err, sql.ErrNoRows) {
+		returnError(w, fmt.Errorf("you already have a collection named %q", name), 409)
+		return
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	writeCollection(w, collection, 201)
+}
+
+func (cfg *apiConfig) handlerGetCollections(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	collections, err := cfg.db.GetCollectionsForUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	responseData := []collectionInfo{}
+	for _, c := range collections {
+		responseData = append(responseData, newCollectionInfo(c))
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerGetCollection(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	collection, _, ok := cfg.visibleCollection(w, r)
+	if !ok {
+		return
+	}
+
+	writeCollection(w, collection, 200)
+}
+
+func (cfg *apiConfig) handlerPatchCollection(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	collection, ok := cfg.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	type requestVals struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Private     *bool   `json:"private"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	updateCollectionArgs := database.UpdateCollectionParams{
+		ID:          collection.ID,
+		Name:        collection.Name,
+		Description: collection.Description,
+		IsPrivate:   collection.IsPrivate,
+	}
+	if params.Name != nil {
+		updateCollectionArgs.Name = strings.TrimSpace(*params.Name)
+	}
+	if params.Description != nil {
+		updateCollectionArgs.Description = *params.Description
+	}
+	if params.Private != nil {
+		updateCollectionArgs.IsPrivate = *params.Private
+	}
+	if err := validateCollection(updateCollectionArgs.Name, updateCollectionArgs.Description); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	updated, err := cfg.db.UpdateCollection(r.Context(), updateCollectionArgs)
+	if errors.Is(err, sql.ErrNoRows) {
+		returnError(w, fmt.Errorf("you already have a collection named %q", updateCollectionArgs.Name), 409)
+		return
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	writeCollection(w, updated, 200)
+}
+
+func (cfg *apiConfig) handlerDeleteCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := cfg.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cfg.db.DeleteCollection(r.Context(), collection.ID); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerPostCollectionChirp(w http.ResponseWriter, r *http.Request) {
+	collection, ok := cfg.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	type requestVals struct {
+		ChirpID uuid.UUID `json:"chirp_id"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(r.Context(), params.ChirpID)
+	if err != nil || chirp.HiddenAt.Valid {
+		returnError(w, fmt.Errorf("chirp not found"), 404)
+		return
+	}
+	blocked, err := cfg.blockedBetween(r.Context(), collection.UserID, chirp.UserID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if blocked {
+		returnError(w, fmt.Errorf("chirp not found"), 404)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Filing a chirp in a collection bookmarks it as well.
+	createBookmarkArgs := database.CreateBookmarkParams{
+		UserID:  collection.UserID,
+		ChirpID: chirp.ID,
+	}
+	if err := qtx.CreateBookmark(r.Context(), createBookmarkArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	addChirpToCollectionArgs := database.AddChirpToCollectionParams{
+		CollectionID: collection.ID,
+		ChirpID:      chirp.ID,
+	}
+	if err := qtx.AddChirpToCollection(r.Context(), addChirpToCollectionArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerDeleteCollectionChirp(w http.ResponseWriter, r *http.Request) {
+	collection, ok := cfg.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	removeChirpFromCollectionArgs := database.RemoveChirpFromCollectionParams{
+		CollectionID: collection.ID,
+		ChirpID:      chirpID,
+	}
+	n, err := cfg.db.RemoveChirpFromCollection(r.Context(), removeChirpFromCollectionArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if n == 0 {
+		returnError(w, fmt.Errorf("chirp is not in this collection"), 404)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetCollectionChirps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	collection, viewer, ok := cfg.visibleCollection(w, r)
+	if !ok {
+		return
+	}
+
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	// Blocks are checked against whoever is looking, so a public collection
+	// never shows a chirp its viewer couldn't otherwise see.
+	getCollectionChirpsArgs := database.GetCollectionChirpsParams{
+		CollectionID: collection.ID,
+		ViewerID:     viewer.UUID,
+		BeforeTime:   cursor.Time,
+		BeforeID:     cursor.ID,
+		PageSize:     int32(limit + 1),
+	}
+	rows, err := cfg.db.GetCollectionChirps(r.Context(), getCollectionChirpsArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	chirps := make([]database.Chirp, 0, len(rows))
+	savedAt := make([]time.Time, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, database.Chirp{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body:      row.Body,
+			UserID:    row.UserID,
+			HiddenAt:  row.HiddenAt,
+			DeletedAt: row.DeletedAt,
+			EditedAt:  row.EditedAt,
+		})
+		savedAt = append(savedAt, row.SavedAt)
+	}
+
+	p, err := savedChirpsPage(r.Context(), cfg.db, viewer, chirps, savedAt, limit)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	writePage(w, p)
+}
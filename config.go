@@ -1,19 +1,56 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
 	"fmt"
 	"net/http"
+	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/blobstore"
 	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/entitlements"
+	"github.com/MagnusTrier/chirpy/internal/moderation"
+	"github.com/MagnusTrier/chirpy/internal/oidc"
+	"github.com/MagnusTrier/chirpy/internal/pubsub"
+	"github.com/MagnusTrier/chirpy/internal/ratelimit"
+	"github.com/MagnusTrier/chirpy/internal/webhooks"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             *database.Queries
-	platform       string
-	jwtSecret      string
-	polkaKey       string
+	fileserverHits  atomic.Int32
+	db              *database.Queries
+	dbConn          *sql.DB
+	platform        string
+	jwtSecret       string
+	polkaSecrets    []string
+	polkaTolerance  time.Duration
+	adminKey        string
+	subGrace        time.Duration
+	trashRetention  time.Duration
+	editWindow      time.Duration
+	passwordPolicy  auth.PasswordPolicy
+	hashParams      auth.HashParams
+	oidcProviders   map[string]*oidc.Provider
+	entitlements    entitlements.Config
+	chirpLimiter    *ratelimit.Limiter
+	webhookSender   *webhooks.Sender
+	moderator       *moderation.Moderator
+	blobStore       blobstore.BlobStore
+	mediaMaxBytes   int64
+	mediaMaxPixels  int64
+	mediaWake       chan struct{}
+	broker          pubsub.Broker
+	streamHeartbeat time.Duration
+	// streams is cancelled when the server shuts down, ending every SSE and
+	// WebSocket connection; streamConns tracks the hijacked WebSockets,
+	// which http.Server.Shutdown doesn't wait for.
+	streams     context.Context
+	streamConns sync.WaitGroup
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -45,3 +82,24 @@ func (cfg *apiConfig) handlerResetUsers(w http.ResponseWriter, r *http.Request)
 	}
 	w.WriteHeader(200)
 }
+
+// requireAdmin checks for the admin API key and writes a 401 or 403 when it is
+// missing. Admin endpoints are disabled entirely when no key is configured.
+func (cfg *apiConfig) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if cfg.adminKey == "" {
+		returnError(w, fmt.Errorf("admin api is disabled"), 403)
+		return false
+	}
+
+	key, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key), []byte(cfg.adminKey)) != 1 {
+		returnError(w, fmt.Errorf("invalid api key"), 403)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,715 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxConversationMembers = 8
+	maxDirectMessageLength = 1000
+)
+
+// Who may start a conversation with a user.
+const (
+	dmPolicyEveryone  = "everyone"
+	dmPolicyFollowing = "following"
+)
+
+var dmPolicies = []string{dmPolicyEveryone, dmPolicyFollowing}
+
+type conversationMemberInfo struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type conversationInfo struct {
+	ID          uuid.UUID                `json:"id"`
+	CreatedAt   time.Time                `json:"created_at"`
+	UpdatedAt   time.Time                `json:"updated_at"`
+	Group       bool                     `json:"group"`
+	Members     []conversationMemberInfo `json:"members"`
+	UnreadCount int64                    `json:"unread_count"`
+}
+
+type directMessageInfo struct {
+	ID             uuid.UUID   `json:"id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	SenderID       uuid.UUID   `json:"sender_id"`
+	Body           string      `json:"body"`
+	ReadBy         []uuid.UUID `json:"read_by"`
+}
+
+func newConversationInfo(c database.Conversation, members []database.ConversationMember, unread int64) conversationInfo {
+	info := conversationInfo{
+		ID:          c.ID,
+		CreatedAt:   c.CreatedAt,
+		UpdatedAt:   c.UpdatedAt,
+		Group:       c.IsGroup,
+		Members:     []conversationMemberInfo{},
+		UnreadCount: unread,
+	}
+	for _, m := range members {
+		member := conversationMemberInfo{UserID: m.UserID}
+		if m.LastReadAt.Valid {
+			member.LastReadAt = &m.LastReadAt.Time
+		}
+		info.Members = append(info.Members, member)
+	}
+	return info
+}
+
+// newDirectMessageInfo fills in read receipts: every other member whose read
+// marker has reached the message.
+func newDirectMessageInfo(m database.DirectMessage, members []database.ConversationMember) directMessageInfo {
+	info := directMessageInfo{
+		ID:             m.ID,
+		CreatedAt:      m.CreatedAt,
+		ConversationID: m.ConversationID,
+		SenderID:       m.SenderID,
+		Body:           m.Body,
+		ReadBy:         []uuid.UUID{},
+	}
+	for _, member := range members {
+		if member.UserID == m.SenderID || !member.LastReadAt.Valid {
+			continue
+		}
+		if !member.LastReadAt.Time.Before(m.CreatedAt) {
+			info.ReadBy = append(info.ReadBy, member.UserID)
+		}
+	}
+	return info
+}
+
+func memberIDs(members []database.ConversationMember) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.UserID)
+	}
+	return ids
+}
+
+// directKey names the one-to-one conversation between two users, whichever
+// of them starts it.
+func directKey(a, b uuid.UUID) string {
+	ids := []string{a.String(), b.String()}
+	slices.Sort(ids)
+	return strings.Join(ids, ":")
+}
+
+// canMessage reports whether sender may message recipient: neither has
+// blocked the other, and the recipient's DM policy lets the sender in.
+func (cfg *apiConfig) canMessage(ctx context.Context, senderID uuid.UUID, recipient database.User) (bool, error) {
+	blocked, err := cfg.blockedBetween(ctx, senderID, recipient.ID)
+	if err != nil || blocked {
+		return false, err
+	}
+
+	if recipient.DmPolicy != dmPolicyFollowing {
+		return true, nil
+	}
+	isFollowingArgs := database.IsFollowingParams{
+		FollowerID: recipient.ID,
+		FollowedID: senderID,
+	}
+	return cfg.db.IsFollowing(ctx, isFollowingArgs)
+}
+
+// checkDirectMessage validates and moderates a message body.
+func (cfg *apiConfig) checkDirectMessage(w http.ResponseWriter, body string) (string, bool) {
+	if strings.TrimSpace(body) == "" || len(body) > maxDirectMessageLength {
+		returnError(w, fmt.Errorf("messages must be between 1 and %d characters", maxDirectMessageLength), 400)
+		return "", false
+	}
+
+	moderated := cfg.moderator.Check(body)
+	if moderated.Rejected() {
+		returnModerationRejected(w, moderated)
+		return "", false
+	}
+	return moderated.Text, true
+}
+
+// sendDirectMessage stores a message and moves the sender's read marker to
+// it, so senders never see their own messages as unread.
+func sendDirectMessage(ctx context.Context, qtx *database.Queries, conversationID, senderID uuid.UUID, body string) (database.DirectMessage, error) {
+	createDirectMessageArgs := database.CreateDirectMessageParams{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Body:           body,
+	}
+	message, err := qtx.CreateDirectMessage(ctx, createDirectMessageArgs)
+	if err != nil {
+		return database.DirectMessage{}, err
+	}
+
+	if err := qtx.TouchConversation(ctx, conversationID); err != nil {
+		return database.DirectMessage{}, err
+	}
+
+	markConversationReadArgs := database.MarkConversationReadParams{
+		ReadAt:         message.CreatedAt,
+		ConversationID: conversationID,
+		UserID:         senderID,
+	}
+	if err := qtx.MarkConversationRead(ctx, markConversationReadArgs); err != nil {
+		return database.DirectMessage{}, err
+	}
+	return message, nil
+}
+
+func (cfg *apiConfig) writeConversation(w http.ResponseWriter, ctx context.Context, userID uuid.UUID, conversation database.Conversation, code int) {
+	members, err := cfg.db.GetConversationMembers(ctx, []uuid.UUID{conversation.ID})
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	countUnreadDirectMessagesArgs := database.CountUnreadDirectMessagesParams{
+		UserID:         userID,
+		ConversationID: conversation.ID,
+	}
+	unread, err := cfg.db.CountUnreadDirectMessages(ctx, countUnreadDirectMessagesArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(newConversationInfo(conversation, members, unread))
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// conversationForMember authenticates the request and loads the conversation
+// in the path along with its members. Non-members get a 404.
+func (cfg *apiConfig) conversationForMember(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.Conversation, []database.ConversationMember, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return uuid.UUID{}, database.Conversation{}, nil, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return uuid.UUID{}, database.Conversation{}, nil, false
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return uuid.UUID{}, database.Conversation{}, nil, false
+	}
+
+	conversation, err := cfg.db.GetConversation(r.Context(), conversationID)
+	if err != nil {
+		returnError(w, fmt.Errorf("conversation not found"), 404)
+		return uuid.UUID{}, database.Conversation{}, nil, false
+	}
+
+	members, err := cfg.db.GetConversationMembers(r.Context(), []uuid.UUID{conversation.ID})
+	if err != nil {
+		returnError(w, err, 500)
+		return uuid.UUID{}, database.Conversation{}, nil, false
+	}
+	if !slices.ContainsFunc(members, func(m database.ConversationMember) bool { return m.UserID == userID }) {
+		returnError(w, fmt.Errorf("conversation not found"), 404)
+		return uuid.UUID{}, database.Conversation{}, nil, false
+	}
+
+	return userID, conversation, members, true
+}
+
+func (cfg *apiConfig) handlerPostConversations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	type requestVals struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+		Body           string      `json:"body"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	participants := []uuid.UUID{}
+	for _, id := range params.ParticipantIDs {
+		if id != userID && !slices.Contains(participants, id) {
+			participants = append(participants, id)
+		}
+	}
+	if len(participants) == 0 || len(participants)+1 > maxConversationMembers {
+		returnError(w, fmt.Errorf("conversations need between 2 and %d members", maxConversationMembers), 400)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if isSuspended(user) {
+		returnSuspended(w, user)
+		return
+	}
+
+	for _, id := range participants {
+		recipient, err := cfg.db.GetUser(r.Context(), id)
+		if err != nil {
+			returnError(w, fmt.Errorf("user %s not found", id), 404)
+			return
+		}
+		allowed, err := cfg.canMessage(r.Context(), userID, recipient)
+		if err != nil {
+			returnError(w, err, 500)
+			return
+		}
+		if !allowed {
+			returnError(w, fmt.Errorf("user %s doesn't accept messages from you", id), 403)
+			return
+		}
+	}
+
+	var body string
+	if params.Body != "" {
+		var ok bool
+		body, ok = cfg.checkDirectMessage(w, params.Body)
+		if !ok {
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	createConversationArgs := database.CreateConversationParams{
+		CreatedBy: userID,
+		IsGroup:   len(participants) > 1,
+	}
+	if !createConversationArgs.IsGroup {
+		createConversationArgs.DirectKey = sql.NullString{String: directKey(userID, participants[0]), Valid: true}
+	}
+
+	code := 201
+	conversation, err := qtx.CreateConversation(r.Context(), createConversationArgs)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The pair already talk; carry on in their existing conversation.
+		conversation, err = qtx.GetConversationByDirectKey(r.Context(), createConversationArgs.DirectKey)
+		code = 200
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	for _, id := range append([]uuid.UUID{userID}, participants...) {
+		addConversationMemberArgs := database.AddConversationMemberParams{
+			ConversationID: conversation.ID,
+			UserID:         id,
+		}
+		if err := qtx.AddConversationMember(r.Context(), addConversationMemberArgs); err != nil {
+			returnError(w, err, 500)
+			return
+		}
+	}
+
+	var message database.DirectMessage
+	if body != "" {
+		message, err = sendDirectMessage(r.Context(), qtx, conversation.ID, userID, body)
+		if err != nil {
+			returnError(w, err, 500)
+			return
+		}
+		// Pick up the new updated_at.
+		conversation, err = qtx.GetConversation(r.Context(), conversation.ID)
+		if err != nil {
+			returnError(w, err, 500)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if body != "" {
+		cfg.publishToMembers(r.Context(), userID, append([]uuid.UUID{userID}, participants...), eventMessage, newDirectMessageInfo(message, nil))
+	}
+
+	cfg.writeConversation(w, r.Context(), userID, conversation, code)
+}
+
+func (cfg *apiConfig) handlerGetConversations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	getConversationsForUserArgs := database.GetConversationsForUserParams{
+		UserID:     userID,
+		BeforeTime: cursor.Time,
+		BeforeID:   cursor.ID,
+		PageSize:   int32(limit + 1),
+	}
+	rows, err := cfg.db.GetConversationsForUser(r.Context(), getConversationsForUserArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	members, err := cfg.db.GetConversationMembers(r.Context(), ids)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	byConversation := map[uuid.UUID][]database.ConversationMember{}
+	for _, m := range members {
+		byConversation[m.ConversationID] = append(byConversation[m.ConversationID], m)
+	}
+
+	items := make([]conversationInfo, 0, len(rows))
+	for _, row := range rows {
+		conversation := database.Conversation{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			CreatedBy: row.CreatedBy,
+			IsGroup:   row.IsGroup,
+			DirectKey: row.DirectKey,
+		}
+		items = append(items, newConversationInfo(conversation, byConversation[row.ID], row.UnreadCount))
+	}
+
+	writePage(w, newPage(items, limit, func(c conversationInfo) pageCursor {
+		return pageCursor{Time: c.UpdatedAt, ID: c.ID}
+	}))
+}
+
+func (cfg *apiConfig) handlerGetConversation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, conversation, _, ok := cfg.conversationForMember(w, r)
+	if !ok {
+		return
+	}
+
+	cfg.writeConversation(w, r.Context(), userID, conversation, 200)
+}
+
+func (cfg *apiConfig) handlerGetDirectMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, conversation, members, ok := cfg.conversationForMember(w, r)
+	if !ok {
+		return
+	}
+
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	// In group conversations, messages from people the reader has a block
+	// with are left out rather than stopping the whole group.
+	getDirectMessagesArgs := database.GetDirectMessagesParams{
+		ConversationID: conversation.ID,
+		ViewerID:       userID,
+		BeforeTime:     cursor.Time,
+		BeforeID:       cursor.ID,
+		PageSize:       int32(limit + 1),
+	}
+	messages, err := cfg.db.GetDirectMessages(r.Context(), getDirectMessagesArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	items := make([]directMessageInfo, 0, len(messages))
+	for _, m := range messages {
+		items = append(items, newDirectMessageInfo(m, members))
+	}
+
+	writePage(w, newPage(items, limit, func(m directMessageInfo) pageCursor {
+		return pageCursor{Time: m.CreatedAt, ID: m.ID}
+	}))
+}
+
+func (cfg *apiConfig) handlerPostDirectMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, conversation, members, ok := cfg.conversationForMember(w, r)
+	if !ok {
+		return
+	}
+
+	type requestVals struct {
+		Body string `json:"body"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if isSuspended(user) {
+		returnSuspended(w, user)
+		return
+	}
+
+	// Blocks and DM policies are rechecked on every one-to-one message, so
+	// a block or a policy change takes effect in existing conversations too.
+	if !conversation.IsGroup {
+		for _, m := range members {
+			if m.UserID == userID {
+				continue
+			}
+			recipient, err := cfg.db.GetUser(r.Context(), m.UserID)
+			if err != nil {
+				returnError(w, err, 500)
+				return
+			}
+			allowed, err := cfg.canMessage(r.Context(), userID, recipient)
+			if err != nil {
+				returnError(w, err, 500)
+				return
+			}
+			if !allowed {
+				returnError(w, fmt.Errorf("this user doesn't accept messages from you"), 403)
+				return
+			}
+		}
+	}
+
+	body, ok := cfg.checkDirectMessage(w, params.Body)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	message, err := sendDirectMessage(r.Context(), qtx, conversation.ID, userID, body)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	cfg.publishToMembers(r.Context(), userID, memberIDs(members), eventMessage, newDirectMessageInfo(message, nil))
+
+	data, err := json.Marshal(newDirectMessageInfo(message, nil))
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(201)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerPostConversationRead(w http.ResponseWriter, r *http.Request) {
+	userID, conversation, members, ok := cfg.conversationForMember(w, r)
+	if !ok {
+		return
+	}
+
+	type requestVals struct {
+		MessageID *uuid.UUID `json:"message_id"`
+	}
+
+	defer r.Body.Close()
+
+	// The body is optional; without a message everything so far is read.
+	params := requestVals{}
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&params); err != nil {
+			returnError(w, err, 400)
+			return
+		}
+	}
+
+	readAt := time.Now().UTC()
+	if params.MessageID != nil {
+		message, err := cfg.db.GetDirectMessage(r.Context(), *params.MessageID)
+		if err != nil || message.ConversationID != conversation.ID {
+			returnError(w, fmt.Errorf("message not found"), 404)
+			return
+		}
+		readAt = message.CreatedAt
+	}
+
+	// Read markers only move forward.
+	markConversationReadArgs := database.MarkConversationReadParams{
+		ReadAt:         readAt,
+		ConversationID: conversation.ID,
+		UserID:         userID,
+	}
+	if err := cfg.db.MarkConversationRead(r.Context(), markConversationReadArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	cfg.publishToMembers(r.Context(), userID, memberIDs(members), eventRead, streamRead{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+		ReadAt:         readAt,
+	})
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetDMSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	writeDMSettings(w, user)
+}
+
+func (cfg *apiConfig) handlerPutDMSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	type requestVals struct {
+		DMPolicy string `json:"dm_policy"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	if !slices.Contains(dmPolicies, params.DMPolicy) {
+		returnError(w, fmt.Errorf("dm_policy must be one of %s", strings.Join(dmPolicies, ", ")), 400)
+		return
+	}
+
+	setUserDMPolicyArgs := database.SetUserDMPolicyParams{
+		ID:       userID,
+		DmPolicy: params.DMPolicy,
+	}
+	user, err := cfg.db.SetUserDMPolicy(r.Context(), setUserDMPolicyArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	writeDMSettings(w, user)
+}
+
+func writeDMSettings(w http.ResponseWriter, user database.User) {
+	data, err := json.Marshal(map[string]string{"dm_policy": user.DmPolicy})
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/entitlements"
+)
+
+// planFor returns the entitlements user currently has, going by their
+// subscription rather than the is_chirpy_red column.
+func (cfg *apiConfig) planFor(ctx context.Context, user database.User) (entitlements.Plan, error) {
+	sub, entitled, err := cfg.entitledSubscription(ctx, user.ID)
+	if err != nil {
+		return entitlements.Plan{}, err
+	}
+	if !entitled {
+		return cfg.entitlements.ForPlan(""), nil
+	}
+
+	return cfg.entitlements.ForPlan(sub.Plan), nil
+}
+
+// returnMissingEntitlement answers 402 when upgrading would grant the
+// entitlement and 403 when no plan does.
+func (cfg *apiConfig) returnMissingEntitlement(w http.ResponseWriter, entitlement string) {
+	code := 403
+	if cfg.entitlements.Purchasable(entitlement) {
+		code = 402
+	}
+	w.WriteHeader(code)
+
+	type returnValsError struct {
+		Msg         string `json:"error"`
+		Entitlement string `json:"entitlement"`
+	}
+
+	data, err := json.Marshal(returnValsError{
+		Msg:         fmt.Sprintf("Error: your plan does not include %s", entitlement),
+		Entitlement: entitlement,
+	})
+	if err != nil {
+		fmt.Print(err)
+		return
+	}
+	w.Write(data)
+}
+
+// requireEntitlement writes the missing entitlement response and returns false
+// when plan lacks feature.
+func (cfg *apiConfig) requireEntitlement(w http.ResponseWriter, plan entitlements.Plan, feature string) bool {
+	if plan.Has(feature) {
+		return true
+	}
+	cfg.returnMissingEntitlement(w, feature)
+	return false
+}
+
+func returnRateLimited(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	returnError(w, fmt.Errorf("rate limit exceeded, retry in %v", retryAfter.Round(time.Second)), 429)
+}
+
+func (cfg *apiConfig) handlerGetEntitlements(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	plan, err := cfg.planFor(r.Context(), user)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
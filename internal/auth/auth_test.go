@@ -2,6 +2,7 @@ package auth
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -86,3 +87,169 @@ func TestGetBearerToken_Missing(t *testing.T) {
 		t.Fatalf("expected missing auth header error, got nil")
 	}
 }
+
+func TestPasswordPolicy_Valid(t *testing.T) {
+	p := DefaultPasswordPolicy
+
+	violations := p.Validate("correct horse battery", "walt@example.com")
+	if len(violations) != 0 {
+		t.Fatalf("expected no violations, got %v", violations)
+	}
+}
+
+func TestPasswordPolicy_Violations(t *testing.T) {
+	breached, err := ParseBreachedPasswords(strings.NewReader(
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n",
+	))
+	if err != nil {
+		t.Fatalf("ParseBreachedPasswords returned error: %v", err)
+	}
+
+	p := DefaultPasswordPolicy
+	p.Breached = breached
+
+	cases := []struct {
+		password string
+		rule     string
+	}{
+		{"", RuleRequired},
+		{"short", RuleMinLength},
+		{strings.Repeat("a", 300), RuleMaxLength},
+		{"Walt@Example.com", RuleNotEmail},
+		{"password", RuleNotBreached},
+	}
+
+	for _, c := range cases {
+		violations := p.Validate(c.password, "walt@example.com")
+		found := false
+		for _, v := range violations {
+			if v.Rule == c.rule {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected %s violation for %q, got %v", c.rule, c.password, violations)
+		}
+	}
+}
+
+func TestCheckPasswordHash_NeedsRehash(t *testing.T) {
+	old := HashParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 1}
+	current := HashParams{Memory: 8 * 1024, Iterations: 2, Parallelism: 1}
+
+	hash, err := HashPassword("hunter22", old)
+	if err != nil {
+		t.Fatalf("HashPassword returned error: %v", err)
+	}
+
+	match, needsRehash, err := CheckPasswordHash("hunter22", hash, current)
+	if err != nil || !match {
+		t.Fatalf("expected match, got %v (err %v)", match, err)
+	}
+	if !needsRehash {
+		t.Errorf("expected needsRehash for outdated params")
+	}
+
+	match, needsRehash, err = CheckPasswordHash("hunter22", hash, old)
+	if err != nil || !match {
+		t.Fatalf("expected match, got %v (err %v)", match, err)
+	}
+	if needsRehash {
+		t.Errorf("expected no rehash when params are current")
+	}
+
+	match, _, _ = CheckPasswordHash("wrong", hash, old)
+	if match {
+		t.Errorf("expected wrong password not to match")
+	}
+}
+
+func TestNewHashParams(t *testing.T) {
+	params, err := NewHashParams(64*1024, 3, 2)
+	if err != nil {
+		t.Fatalf("NewHashParams returned error: %v", err)
+	}
+	if params != (HashParams{Memory: 64 * 1024, Iterations: 3, Parallelism: 2}) {
+		t.Errorf("unexpected params %+v", params)
+	}
+
+	cases := []struct {
+		memory, iterations, parallelism int
+	}{
+		{64 * 1024, 0, 2},
+		{64 * 1024, 3, 0},
+		{64 * 1024, 3, 256},
+		{15, 3, 2},
+	}
+	for _, c := range cases {
+		if _, err := NewHashParams(c.memory, c.iterations, c.parallelism); err == nil {
+			t.Errorf("expected error for memory=%d iterations=%d parallelism=%d", c.memory, c.iterations, c.parallelism)
+		}
+	}
+}
+
+func TestValidateJWTScope(t *testing.T) {
+	userID := uuid.New()
+	secret := "this is my secret"
+
+	token, err := MakeScopedJWT(userID, secret, time.Minute, "client-1", ScopeChirpsRead)
+	if err != nil {
+		t.Fatalf("MakeScopedJWT returned error: %v", err)
+	}
+
+	if _, err := ValidateJWT(token, secret); err == nil {
+		t.Errorf("expected ValidateJWT to reject a third-party token")
+	}
+
+	if id, err := ValidateJWTScope(token, secret, ScopeChirpsRead); err != nil || id != userID {
+		t.Errorf("expected granted scope to validate, got %v (err %v)", id, err)
+	}
+
+	if _, err := ValidateJWTScope(token, secret, ScopeChirpsWrite); err == nil {
+		t.Errorf("expected missing scope to be rejected")
+	}
+
+	firstParty, err := MakeJWT(userID, secret, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT returned error: %v", err)
+	}
+	if _, err := ValidateJWTScope(firstParty, secret, ScopeChirpsWrite); err != nil {
+		t.Errorf("expected first-party token to pass scope check, got %v", err)
+	}
+}
+
+func TestCheckCodeChallenge(t *testing.T) {
+	// Example from RFC 7636 appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if !CheckCodeChallenge(verifier, challenge) {
+		t.Errorf("expected verifier to match challenge")
+	}
+	if CheckCodeChallenge(verifier+"x", challenge) {
+		t.Errorf("expected different verifier not to match")
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded"}`)
+	now := time.Unix(1700000000, 0)
+	ts := "1700000000"
+	sig := SignWebhook("old-secret", ts, body)
+
+	if err := VerifyWebhookSignature([]string{"new-secret", "old-secret"}, ts, "v1="+sig, body, 5*time.Minute, now); err != nil {
+		t.Errorf("expected signature from rotated out secret to verify, got %v", err)
+	}
+
+	if err := VerifyWebhookSignature([]string{"new-secret"}, ts, sig, body, 5*time.Minute, now); err == nil {
+		t.Errorf("expected unknown secret to fail")
+	}
+
+	if err := VerifyWebhookSignature([]string{"old-secret"}, ts, sig, []byte(`{"event":"tampered"}`), 5*time.Minute, now); err == nil {
+		t.Errorf("expected tampered body to fail")
+	}
+
+	if err := VerifyWebhookSignature([]string{"old-secret"}, ts, sig, body, 5*time.Minute, now.Add(10*time.Minute)); err == nil {
+		t.Errorf("expected replayed delivery outside tolerance to fail")
+	}
+}
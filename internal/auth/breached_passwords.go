@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const breachedPrefixLen = 5
+
+// BreachedPasswords holds a Pwned Passwords style list of SHA-1 hashes,
+// bucketed by their first five hex characters the same way the k-anonymity
+// range API is, so a lookup only ever touches one small bucket.
+type BreachedPasswords struct {
+	ranges map[string]map[string]int
+}
+
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseBreachedPasswords(f)
+}
+
+// ParseBreachedPasswords reads lines of the form "HASH:COUNT", where HASH is
+// the full uppercase SHA-1 of a password. The count is optional.
+func ParseBreachedPasswords(r io.Reader) (*BreachedPasswords, error) {
+	b := &BreachedPasswords{ranges: map[string]map[string]int{}}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, countStr, hasCount := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("breached passwords line %d: invalid hash length", lineNo)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("breached passwords line %d: %w", lineNo, err)
+		}
+
+		count := 1
+		if hasCount {
+			n, err := strconv.Atoi(countStr)
+			if err != nil {
+				return nil, fmt.Errorf("breached passwords line %d: %w", lineNo, err)
+			}
+			count = n
+		}
+
+		prefix, suffix := hash[:breachedPrefixLen], hash[breachedPrefixLen:]
+		if b.ranges[prefix] == nil {
+			b.ranges[prefix] = map[string]int{}
+		}
+		b.ranges[prefix][suffix] = count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Range returns the hash suffixes and counts known for a five character
+// prefix, mirroring the range endpoint of the Pwned Passwords API.
+func (b *BreachedPasswords) Range(prefix string) map[string]int {
+	return b.ranges[strings.ToUpper(prefix)]
+}
+
+func (b *BreachedPasswords) Count(password string) int {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return b.Range(hash[:breachedPrefixLen])[hash[breachedPrefixLen:]]
+}
+
+func (b *BreachedPasswords) Contains(password string) bool {
+	return b.Count(password) > 0
+}
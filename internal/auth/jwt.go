@@ -10,14 +10,30 @@ import (
 	"github.com/google/uuid"
 )
 
+// Claims are the claims Chirpy puts in its access tokens. ClientID and Scope
+// are only set on tokens issued to third-party OAuth clients.
+type Claims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+}
+
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return MakeScopedJWT(userID, tokenSecret, expiresIn, "", "")
+}
+
+func MakeScopedJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration, clientID, scope string) (string, error) {
 	token := jwt.NewWithClaims(
 		jwt.SigningMethodHS256,
-		jwt.RegisteredClaims{
-			Issuer:    "chirpy",
-			IssuedAt:  jwt.NewNumericDate(time.Now().In(time.UTC)),
-			ExpiresAt: jwt.NewNumericDate(time.Now().In(time.UTC).Add(expiresIn)),
-			Subject:   userID.String(),
+		Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "chirpy",
+				IssuedAt:  jwt.NewNumericDate(time.Now().In(time.UTC)),
+				ExpiresAt: jwt.NewNumericDate(time.Now().In(time.UTC).Add(expiresIn)),
+				Subject:   userID.String(),
+			},
+			ClientID: clientID,
+			Scope:    scope,
 		},
 	)
 
@@ -30,8 +46,8 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claims := jwt.RegisteredClaims{}
+func ParseJWT(tokenString, tokenSecret string) (Claims, error) {
+	claims := Claims{}
 
 	keyF := func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -42,10 +58,43 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 
 	tok, err := jwt.ParseWithClaims(tokenString, &claims, keyF)
 	if err != nil {
-		return uuid.UUID{}, err
+		return Claims{}, err
 	}
 	if !tok.Valid {
-		return uuid.UUID{}, fmt.Errorf("invalid token")
+		return Claims{}, fmt.Errorf("invalid token")
+	}
+
+	return claims, nil
+}
+
+// ValidateJWT only accepts first-party tokens. Handlers that third-party
+// clients may call use ValidateJWTScope instead.
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if claims.ClientID != "" {
+		return uuid.UUID{}, fmt.Errorf("token was issued to a third-party client")
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return userID, nil
+}
+
+// ValidateJWTScope accepts first-party tokens and third-party tokens that were
+// granted scope.
+func ValidateJWTScope(tokenString, tokenSecret, scope string) (uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if claims.ClientID != "" && !HasScope(claims.Scope, scope) {
+		return uuid.UUID{}, fmt.Errorf("token is missing scope %s", scope)
 	}
 
 	userID, err := uuid.Parse(claims.Subject)
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+const (
+	ScopeChirpsRead  = "chirps:read"
+	ScopeChirpsWrite = "chirps:write"
+	ScopeWebhooks    = "webhooks:manage"
+)
+
+var SupportedScopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeWebhooks}
+
+// ParseScope splits a space separated OAuth scope string, dropping duplicates.
+func ParseScope(scope string) []string {
+	scopes := []string{}
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+func HasScope(scope, want string) bool {
+	return slices.Contains(strings.Fields(scope), want)
+}
+
+// ScopeSubset reports whether every scope in requested is also in allowed.
+func ScopeSubset(requested, allowed string) bool {
+	for _, s := range strings.Fields(requested) {
+		if !HasScope(allowed, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// HashToken hashes high entropy secrets such as client secrets and
+// authorization codes for storage. Unlike passwords they don't need argon2.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func CheckTokenHash(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(hash)) == 1
+}
+
+// CheckCodeChallenge verifies a PKCE code verifier against an S256 challenge.
+func CheckCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
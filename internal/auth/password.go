@@ -1,16 +1,102 @@
 package auth
 
-import "github.com/alexedwards/argon2id"
+import (
+	"fmt"
+	"math"
+	"time"
 
-func HashPassword(password string) (string, error) {
-	hashed, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+	"github.com/alexedwards/argon2id"
+)
+
+type HashParams struct {
+	Memory      uint32 `json:"memory"`
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+}
+
+// DefaultHashParams uses a fixed parallelism rather than the core count, so
+// instances on different hardware don't keep rehashing each other's hashes.
+var DefaultHashParams = HashParams{
+	Memory:      argon2id.DefaultParams.Memory,
+	Iterations:  argon2id.DefaultParams.Iterations,
+	Parallelism: 2,
+}
+
+// NewHashParams checks params read from configuration, which argon2 would
+// otherwise only reject by panicking on the first hash.
+func NewHashParams(memory, iterations, parallelism int) (HashParams, error) {
+	if iterations < 1 || iterations > math.MaxUint32 {
+		return HashParams{}, fmt.Errorf("argon2 iterations must be between 1 and %d", uint32(math.MaxUint32))
+	}
+	if parallelism < 1 || parallelism > math.MaxUint8 {
+		return HashParams{}, fmt.Errorf("argon2 parallelism must be between 1 and %d", math.MaxUint8)
+	}
+	if memory < 8*parallelism || memory > math.MaxUint32 {
+		return HashParams{}, fmt.Errorf("argon2 memory must be at least 8 KiB per lane (%d KiB)", 8*parallelism)
+	}
+	return HashParams{
+		Memory:      uint32(memory),
+		Iterations:  uint32(iterations),
+		Parallelism: uint8(parallelism),
+	}, nil
+}
+
+func (p HashParams) argon2id() *argon2id.Params {
+	return &argon2id.Params{
+		Memory:      p.Memory,
+		Iterations:  p.Iterations,
+		Parallelism: p.Parallelism,
+		SaltLength:  argon2id.DefaultParams.SaltLength,
+		KeyLength:   argon2id.DefaultParams.KeyLength,
+	}
+}
+
+func HashPassword(password string, params HashParams) (string, error) {
+	hashed, err := argon2id.CreateHash(password, params.argon2id())
 	if err != nil {
 		return "", err
 	}
 	return hashed, nil
 }
 
-func CheckPasswordHash(password, hash string) (bool, error) {
-	match, err := argon2id.ComparePasswordAndHash(password, hash)
-	return match, err
+// CheckPasswordHash compares password against hash. When the password matches
+// but hash was made with anything other than params, needsRehash is true so
+// the caller can store a fresh hash while it still has the plain text.
+func CheckPasswordHash(password, hash string, params HashParams) (match, needsRehash bool, err error) {
+	match, stored, err := argon2id.CheckHash(password, hash)
+	if err != nil || !match {
+		return false, false, err
+	}
+
+	want := params.argon2id()
+	needsRehash = stored.Memory != want.Memory ||
+		stored.Iterations != want.Iterations ||
+		stored.Parallelism != want.Parallelism ||
+		stored.KeyLength != want.KeyLength
+
+	return true, needsRehash, nil
+}
+
+// CalibrateHashParams keeps memory and parallelism fixed and raises the
+// iteration count until a single hash takes at least target. It returns the
+// chosen params and how long hashing took with them.
+func CalibrateHashParams(target time.Duration, memory uint32, parallelism uint8) (HashParams, time.Duration, error) {
+	params := HashParams{
+		Memory:      memory,
+		Iterations:  1,
+		Parallelism: parallelism,
+	}
+
+	for {
+		start := time.Now()
+		if _, err := HashPassword("calibration password", params); err != nil {
+			return HashParams{}, 0, err
+		}
+		elapsed := time.Since(start)
+
+		if elapsed >= target || params.Iterations >= 64 {
+			return params, elapsed, nil
+		}
+		params.Iterations++
+	}
 }
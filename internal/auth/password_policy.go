@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	RuleRequired    = "required"
+	RuleMinLength   = "min_length"
+	RuleMaxLength   = "max_length"
+	RuleNotEmail    = "not_email"
+	RuleNotBreached = "not_breached"
+)
+
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicy describes the rules a new password must satisfy. MaxLength is
+// counted in bytes since that is what argon2 hashes, and keeps a huge password
+// from tying up the hasher.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	DisallowEmail bool
+	Breached      *BreachedPasswords
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:     8,
+	MaxLength:     256,
+	DisallowEmail: true,
+}
+
+func (p PasswordPolicy) Validate(password, email string) []PolicyViolation {
+	violations := []PolicyViolation{}
+
+	if password == "" {
+		return append(violations, PolicyViolation{
+			Rule:    RuleRequired,
+			Message: "password is required",
+		})
+	}
+
+	if p.MinLength > 0 && utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("password must be at least %d characters", p.MinLength),
+		})
+	}
+
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("password must be at most %d bytes", p.MaxLength),
+		})
+		// Don't hash oversized input just to look it up in the breach list.
+		return violations
+	}
+
+	if p.DisallowEmail && email != "" {
+		local, _, _ := strings.Cut(email, "@")
+		if strings.EqualFold(password, email) || strings.EqualFold(password, local) {
+			violations = append(violations, PolicyViolation{
+				Rule:    RuleNotEmail,
+				Message: "password must not be your email address",
+			})
+		}
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleNotBreached,
+			Message: "password has appeared in a data breach, choose another one",
+		})
+	}
+
+	return violations
+}
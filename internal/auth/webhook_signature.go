@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignWebhook returns the hex encoded HMAC-SHA256 of "timestamp.body".
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks that signatureHeader holds a valid signature
+// of body made with any of secrets, and that timestamp (unix seconds) is within
+// tolerance of now. The header may carry several comma separated signatures,
+// optionally prefixed with "v1=", so senders can sign with old and new secrets
+// while rotating.
+func VerifyWebhookSignature(secrets []string, timestamp, signatureHeader string, body []byte, tolerance time.Duration, now time.Time) error {
+	if timestamp == "" || signatureHeader == "" {
+		return fmt.Errorf("missing webhook signature")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp")
+	}
+	age := now.Sub(time.Unix(ts, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("webhook timestamp outside of tolerance")
+	}
+
+	for _, sig := range strings.Split(signatureHeader, ",") {
+		sig = strings.TrimPrefix(strings.TrimSpace(sig), "v1=")
+		got, err := hex.DecodeString(sig)
+		if err != nil {
+			continue
+		}
+		for _, secret := range secrets {
+			if secret == "" {
+				continue
+			}
+			want, _ := hex.DecodeString(SignWebhook(secret, timestamp, body))
+			if hmac.Equal(got, want) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("invalid webhook signature")
+}
@@ -6,33 +6,361 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Attachment struct {
+	ID                 uuid.UUID      `json:"id"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UserID             uuid.UUID      `json:"user_id"`
+	ChirpID            uuid.NullUUID  `json:"chirp_id"`
+	StorageKey         string         `json:"storage_key"`
+	ContentType        string         `json:"content_type"`
+	SizeBytes          int64          `json:"size_bytes"`
+	Width              int32          `json:"width"`
+	Height             int32          `json:"height"`
+	AltText            string         `json:"alt_text"`
+	Blurhash           sql.NullString `json:"blurhash"`
+	ProcessedAt        sql.NullTime   `json:"processed_at"`
+	ProcessingAttempts int32          `json:"processing_attempts"`
+	RetryAt            sql.NullTime   `json:"retry_at"`
+}
+
+type AttachmentVariant struct {
+	AttachmentID uuid.UUID `json:"attachment_id"`
+	Name         string    `json:"name"`
+	StorageKey   string    `json:"storage_key"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	UrlKey       uuid.UUID `json:"url_key"`
+}
+
+type Block struct {
+	BlockerID uuid.UUID `json:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type Chirp struct {
+	ID        uuid.UUID    `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Body      string       `json:"body"`
+	UserID    uuid.UUID    `json:"user_id"`
+	HiddenAt  sql.NullTime `json:"hidden_at"`
+	DeletedAt sql.NullTime `json:"deleted_at"`
+	EditedAt  sql.NullTime `json:"edited_at"`
+}
+
+type ChirpRevision struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
 	Body      string    `json:"body"`
+}
+
+type Collection struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UserID      uuid.UUID `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	IsPrivate   bool      `json:"is_private"`
+}
+
+type CollectionChirp struct {
+	CollectionID uuid.UUID `json:"collection_id"`
+	ChirpID      uuid.UUID `json:"chirp_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Conversation struct {
+	ID        uuid.UUID      `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	CreatedBy uuid.UUID      `json:"created_by"`
+	IsGroup   bool           `json:"is_group"`
+	DirectKey sql.NullString `json:"direct_key"`
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID    `json:"conversation_id"`
+	UserID         uuid.UUID    `json:"user_id"`
+	JoinedAt       time.Time    `json:"joined_at"`
+	LastReadAt     sql.NullTime `json:"last_read_at"`
+}
+
+type DirectMessage struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FollowedID uuid.UUID `json:"followed_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type List struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UserID      uuid.UUID `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	IsPrivate   bool      `json:"is_private"`
+}
+
+type ListMember struct {
+	ListID    uuid.UUID `json:"list_id"`
 	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ListSubscription struct {
+	ListID    uuid.UUID `json:"list_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ModerationAction struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	Moderator string        `json:"moderator"`
+	Action    string        `json:"action"`
+	ReportID  uuid.NullUUID `json:"report_id"`
+	ChirpID   uuid.NullUUID `json:"chirp_id"`
+	UserID    uuid.NullUUID `json:"user_id"`
+	Note      string        `json:"note"`
+}
+
+type ModerationFlag struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Rule      string    `json:"rule"`
+	Match     string    `json:"match"`
+}
+
+type Mute struct {
+	MuterID   uuid.UUID `json:"muter_id"`
+	MutedID   uuid.UUID `json:"muted_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Notification struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	UserID    uuid.UUID     `json:"user_id"`
+	Type      string        `json:"type"`
+	ChirpID   uuid.NullUUID `json:"chirp_id"`
+	GroupKey  string        `json:"group_key"`
+	ActorIds  []uuid.UUID   `json:"actor_ids"`
+	ReadAt    sql.NullTime  `json:"read_at"`
+}
+
+type NotificationPreference struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Type    string    `json:"type"`
+	Enabled bool      `json:"enabled"`
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string    `json:"code_hash"`
+	CreatedAt     time.Time `json:"created_at"`
+	ClientID      string    `json:"client_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	RedirectUri   string    `json:"redirect_uri"`
+	Scope         string    `json:"scope"`
+	CodeChallenge string    `json:"code_challenge"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+type OauthClient struct {
+	ID           string    `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	UserID       uuid.UUID `json:"user_id"`
+	Name         string    `json:"name"`
+	SecretHash   string    `json:"secret_hash"`
+	RedirectUris []string  `json:"redirect_uris"`
+	Scopes       string    `json:"scopes"`
+}
+
+type OidcAuthRequest struct {
+	State        string    `json:"state"`
+	CreatedAt    time.Time `json:"created_at"`
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type Poll struct {
+	ChirpID           uuid.UUID `json:"chirp_id"`
+	CreatedAt         time.Time `json:"created_at"`
+	ClosesAt          time.Time `json:"closes_at"`
+	MultipleChoice    bool      `json:"multiple_choice"`
+	ResultsVisibility string    `json:"results_visibility"`
+}
+
+type PollOption struct {
+	ID       uuid.UUID `json:"id"`
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	Position int32     `json:"position"`
+	Text     string    `json:"text"`
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID   `json:"chirp_id"`
+	UserID    uuid.UUID   `json:"user_id"`
+	OptionIds []uuid.UUID `json:"option_ids"`
+	CreatedAt time.Time   `json:"created_at"`
 }
 
 type RefreshToken struct {
-	Token     string       `json:"token"`
+	Token     string         `json:"token"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	UserID    uuid.UUID      `json:"user_id"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	RevokedAt sql.NullTime   `json:"revoked_at"`
+	ClientID  sql.NullString `json:"client_id"`
+	Scope     string         `json:"scope"`
+}
+
+type Report struct {
+	ID         uuid.UUID      `json:"id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	ChirpID    uuid.UUID      `json:"chirp_id"`
+	ReporterID uuid.UUID      `json:"reporter_id"`
+	Reason     string         `json:"reason"`
+	Details    string         `json:"details"`
+	Status     string         `json:"status"`
+	Assignee   sql.NullString `json:"assignee"`
+	Resolution sql.NullString `json:"resolution"`
+	ResolvedAt sql.NullTime   `json:"resolved_at"`
+}
+
+type ScheduledChirp struct {
+	ID        uuid.UUID    `json:"id"`
 	CreatedAt time.Time    `json:"created_at"`
 	UpdatedAt time.Time    `json:"updated_at"`
 	UserID    uuid.UUID    `json:"user_id"`
-	ExpiresAt time.Time    `json:"expires_at"`
-	RevokedAt sql.NullTime `json:"revoked_at"`
+	Body      string       `json:"body"`
+	PublishAt time.Time    `json:"publish_at"`
+	Status    string       `json:"status"`
+	LastError string       `json:"last_error"`
+	Attempts  int32        `json:"attempts"`
+	RetryAt   sql.NullTime `json:"retry_at"`
+}
+
+type Subscription struct {
+	ID                uuid.UUID    `json:"id"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+	UserID            uuid.UUID    `json:"user_id"`
+	Plan              string       `json:"plan"`
+	Status            string       `json:"status"`
+	CurrentPeriodEnd  time.Time    `json:"current_period_end"`
+	CancelAtPeriodEnd bool         `json:"cancel_at_period_end"`
+	CanceledAt        sql.NullTime `json:"canceled_at"`
+}
+
+type SubscriptionHistory struct {
+	ID               uuid.UUID      `json:"id"`
+	CreatedAt        time.Time      `json:"created_at"`
+	SubscriptionID   uuid.UUID      `json:"subscription_id"`
+	Event            string         `json:"event"`
+	Plan             string         `json:"plan"`
+	Status           string         `json:"status"`
+	CurrentPeriodEnd time.Time      `json:"current_period_end"`
+	WebhookEventID   sql.NullString `json:"webhook_event_id"`
 }
 
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
-	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ID             uuid.UUID    `json:"id"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	Email          string       `json:"email"`
+	HashedPassword string       `json:"hashed_password"`
+	IsChirpyRed    bool         `json:"is_chirpy_red"`
+	SuspendedUntil sql.NullTime `json:"suspended_until"`
+	DmPolicy       string       `json:"dm_policy"`
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID    `json:"id"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	EndpointID     uuid.UUID    `json:"endpoint_id"`
+	OutboxID       uuid.UUID    `json:"outbox_id"`
+	Status         string       `json:"status"`
+	Attempts       int32        `json:"attempts"`
+	NextAttemptAt  time.Time    `json:"next_attempt_at"`
+	LockedUntil    sql.NullTime `json:"locked_until"`
+	LastStatusCode int32        `json:"last_status_code"`
+	LastError      string       `json:"last_error"`
+	DeliveredAt    sql.NullTime `json:"delivered_at"`
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+}
+
+type WebhookEvent struct {
+	ID            string          `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	EventType     string          `json:"event_type"`
+	UserID        uuid.NullUUID   `json:"user_id"`
+	Payload       json.RawMessage `json:"payload"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	LastError     string          `json:"last_error"`
+	LastAttemptAt sql.NullTime    `json:"last_attempt_at"`
+}
+
+type WebhookOutbox struct {
+	ID           uuid.UUID       `json:"id"`
+	CreatedAt    time.Time       `json:"created_at"`
+	EventType    string          `json:"event_type"`
+	Payload      json.RawMessage `json:"payload"`
+	DispatchedAt sql.NullTime    `json:"dispatched_at"`
+	AuthorID     uuid.NullUUID   `json:"author_id"`
 }
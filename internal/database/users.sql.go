@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
@@ -20,7 +21,7 @@ VALUES (
 	$1,
 	$2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_until, dm_policy
 `
 
 type CreateUserParams struct {
@@ -38,6 +39,35 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedUntil,
+		&i.DmPolicy,
+	)
+	return i, err
+}
+
+const createUserWithoutPassword = `-- name: CreateUserWithoutPassword :one
+INSERT INTO users (id, created_at, updated_at, email)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1
+)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_until, dm_policy
+`
+
+func (q *Queries) CreateUserWithoutPassword(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, createUserWithoutPassword, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedUntil,
+		&i.DmPolicy,
 	)
 	return i, err
 }
@@ -52,7 +82,7 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_until, dm_policy FROM users
 WHERE id = $1
 `
 
@@ -66,12 +96,14 @@ func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedUntil,
+		&i.DmPolicy,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_until, dm_policy FROM users
 WHERE email = $1
 `
 
@@ -85,24 +117,88 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedUntil,
+		&i.DmPolicy,
+	)
+	return i, err
+}
+
+const setUserDMPolicy = `-- name: SetUserDMPolicy :one
+UPDATE users
+SET dm_policy = $2,
+	updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_until, dm_policy
+`
+
+type SetUserDMPolicyParams struct {
+	ID       uuid.UUID `json:"id"`
+	DmPolicy string    `json:"dm_policy"`
+}
+
+func (q *Queries) SetUserDMPolicy(ctx context.Context, arg SetUserDMPolicyParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserDMPolicy, arg.ID, arg.DmPolicy)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedUntil,
+		&i.DmPolicy,
 	)
 	return i, err
 }
 
-const setUserIsChirpyRed = `-- name: SetUserIsChirpyRed :exec
+const setUserHashedPassword = `-- name: SetUserHashedPassword :exec
 UPDATE users
-SET is_chirpy_red = $2,
+SET hashed_password = $2,
 	updated_at = NOW()
 WHERE id = $1
 `
 
-type SetUserIsChirpyRedParams struct {
-	ID          uuid.UUID `json:"id"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+type SetUserHashedPasswordParams struct {
+	ID             uuid.UUID `json:"id"`
+	HashedPassword string    `json:"hashed_password"`
 }
 
-func (q *Queries) SetUserIsChirpyRed(ctx context.Context, arg SetUserIsChirpyRedParams) error {
-	_, err := q.db.ExecContext(ctx, setUserIsChirpyRed, arg.ID, arg.IsChirpyRed)
+func (q *Queries) SetUserHashedPassword(ctx context.Context, arg SetUserHashedPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, setUserHashedPassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users
+SET suspended_until = $2,
+	updated_at = NOW()
+WHERE id = $1
+`
+
+type SuspendUserParams struct {
+	ID             uuid.UUID    `json:"id"`
+	SuspendedUntil sql.NullTime `json:"suspended_until"`
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, arg.ID, arg.SuspendedUntil)
+	return err
+}
+
+const syncUserIsChirpyRed = `-- name: SyncUserIsChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = EXISTS (
+		SELECT 1 FROM subscriptions
+		WHERE subscriptions.user_id = users.id
+		AND subscriptions.status IN ('active', 'past_due')
+	),
+	updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) SyncUserIsChirpyRed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, syncUserIsChirpyRed, id)
 	return err
 }
 
@@ -112,7 +208,7 @@ SET email = $2,
 	hashed_password = $3,
 	updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_until, dm_policy
 `
 
 type UpdateUserParams struct {
@@ -131,6 +227,8 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedUntil,
+		&i.DmPolicy,
 	)
 	return i, err
 }
@@ -0,0 +1,108 @@
+// Package entitlements describes what each subscription plan is allowed to
+// do. Plans are loaded from a JSON file so they can change without a deploy.
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+const (
+	FreePlan = "free"
+
+	EditChirps     = "edit_chirps"
+	ScheduleChirps = "schedule_chirps"
+	MaxChirpLength = "max_chirp_length"
+)
+
+type Plan struct {
+	MaxChirpLength int      `json:"max_chirp_length"`
+	ChirpsPerHour  int      `json:"chirps_per_hour"`
+	Features       []string `json:"features"`
+}
+
+func (p Plan) Has(feature string) bool {
+	return slices.Contains(p.Features, feature)
+}
+
+type Config struct {
+	Plans map[string]Plan `json:"plans"`
+	// PaidFallback is used for paying users whose plan isn't listed, so a new
+	// plan in Polka doesn't leave them with free entitlements.
+	PaidFallback string `json:"paid_fallback"`
+}
+
+var Default = Config{
+	Plans: map[string]Plan{
+		FreePlan: {
+			MaxChirpLength: 140,
+			ChirpsPerHour:  30,
+			Features:       []string{},
+		},
+		"red": {
+			MaxChirpLength: 1000,
+			ChirpsPerHour:  300,
+			Features:       []string{EditChirps, ScheduleChirps},
+		},
+	},
+	PaidFallback: "red",
+}
+
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	c := Config{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Config{}, fmt.Errorf("entitlements %s: %w", path, err)
+	}
+	if _, ok := c.Plans[FreePlan]; !ok {
+		return Config{}, fmt.Errorf("entitlements %s: missing %q plan", path, FreePlan)
+	}
+	if c.PaidFallback != "" {
+		if _, ok := c.Plans[c.PaidFallback]; !ok {
+			return Config{}, fmt.Errorf("entitlements %s: paid_fallback %q is not a plan", path, c.PaidFallback)
+		}
+	}
+
+	return c, nil
+}
+
+// ForPlan returns the plan called name. An empty name means the user isn't
+// paying.
+func (c Config) ForPlan(name string) Plan {
+	if name == "" {
+		return c.Plans[FreePlan]
+	}
+	if p, ok := c.Plans[name]; ok {
+		return p
+	}
+	if p, ok := c.Plans[c.PaidFallback]; ok {
+		return p
+	}
+	return c.Plans[FreePlan]
+}
+
+// Purchasable reports whether any plan grants feature, which decides between
+// telling a user to upgrade and telling them no.
+func (c Config) Purchasable(feature string) bool {
+	for _, p := range c.Plans {
+		if p.Has(feature) {
+			return true
+		}
+	}
+	return false
+}
+
+// LongestChirp is the highest chirp length limit across all plans.
+func (c Config) LongestChirp() int {
+	longest := 0
+	for _, p := range c.Plans {
+		longest = max(longest, p.MaxChirpLength)
+	}
+	return longest
+}
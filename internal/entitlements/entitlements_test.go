@@ -0,0 +1,53 @@
+package entitlements
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entitlements.json")
+	err := os.WriteFile(path, []byte(`{
+		"plans": {
+			"free": {"max_chirp_length": 140, "chirps_per_hour": 10},
+			"red": {"max_chirp_length": 500, "features": ["edit_chirps"]}
+		},
+		"paid_fallback": "red"
+	}`), 0o644)
+	if err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if got := c.ForPlan("").MaxChirpLength; got != 140 {
+		t.Errorf("expected free plan limit 140, got %d", got)
+	}
+	if !c.ForPlan("red_annual").Has(EditChirps) {
+		t.Errorf("expected unknown paid plan to fall back to red")
+	}
+	if c.ForPlan("").Has(EditChirps) {
+		t.Errorf("expected free plan not to edit chirps")
+	}
+	if !c.Purchasable(EditChirps) || c.Purchasable(ScheduleChirps) {
+		t.Errorf("unexpected purchasable features")
+	}
+	if c.LongestChirp() != 500 {
+		t.Errorf("expected longest chirp 500, got %d", c.LongestChirp())
+	}
+}
+
+func TestLoad_RequiresFreePlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entitlements.json")
+	if err := os.WriteFile(path, []byte(`{"plans": {"red": {}}}`), 0o644); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Errorf("expected config without a free plan to be rejected")
+	}
+}
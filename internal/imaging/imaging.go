@@ -0,0 +1,237 @@
+// Package imaging decodes, sanitises and resizes uploaded images. It only uses
+// the standard library's decoders, so PNG, JPEG and GIF are supported.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+const jpegQuality = 85
+
+type Image struct {
+	// Image is the first frame, already rotated upright for JPEGs.
+	Image image.Image
+	// Format is "png", "jpeg" or "gif".
+	Format string
+	// GIF keeps every frame so that animations survive re-encoding.
+	GIF *gif.GIF
+}
+
+// Decode reads the dimensions from the header and refuses anything over
+// maxPixels before decoding, so a small file that claims to be enormous can't
+// exhaust memory.
+func Decode(data []byte, maxPixels int64) (*Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, fmt.Errorf("image has no pixels")
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	switch format {
+	case "gif":
+		// Every frame is decoded at full size, so the frames are counted
+		// before any of them are.
+		frames, err := gifFrames(data, maxPixels/(int64(cfg.Width)*int64(cfg.Height)))
+		if err != nil {
+			return nil, err
+		}
+		if frames*int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+			return nil, ErrTooManyPixels
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return &Image{Image: g.Image[0], Format: format, GIF: g}, nil
+	case "jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		// The orientation lives in the EXIF block that Encode drops, so it
+		// has to be applied to the pixels.
+		return &Image{Image: orient(img, exifOrientation(data)), Format: format}, nil
+	case "png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return &Image{Image: img, Format: format}, nil
+	}
+	return nil, fmt.Errorf("unsupported image format %s", format)
+}
+
+// gifFrames counts the frames in a GIF by walking its blocks without
+// decompressing them. It stops counting once there are more than limit.
+func gifFrames(data []byte, limit int64) (int64, error) {
+	errCorrupt := fmt.Errorf("gif: corrupt block structure")
+
+	// Header and logical screen descriptor, then the global color table.
+	if len(data) < 13 {
+		return 0, errCorrupt
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	// skipSubBlocks moves past a run of length-prefixed sub-blocks.
+	skipSubBlocks := func() bool {
+		for i < len(data) {
+			n := int(data[i])
+			i += 1 + n
+			if n == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	var frames int64
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // extension: introducer, label, sub-blocks
+			i += 2
+			if !skipSubBlocks() {
+				return 0, errCorrupt
+			}
+		case 0x2c: // image descriptor, local color table, LZW code size, sub-blocks
+			if i+10 > len(data) {
+				return 0, errCorrupt
+			}
+			packed := data[i+9]
+			i += 10
+			if packed&0x80 != 0 {
+				i += 3 << (packed&0x07 + 1)
+			}
+			i++
+			if !skipSubBlocks() {
+				return 0, errCorrupt
+			}
+			frames++
+			if frames > limit {
+				return frames, nil
+			}
+		case 0x3b: // trailer
+			return frames, nil
+		default:
+			return 0, errCorrupt
+		}
+	}
+	return 0, errCorrupt
+}
+
+// Encode writes the image back out in its own format. Only pixel data is
+// written, so EXIF, XMP, ICC profiles, text chunks and GIF comments are gone.
+func (im *Image) Encode() ([]byte, error) {
+	if im.GIF != nil {
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, im.GIF); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return EncodeStill(im.Image, im.Format)
+}
+
+// EncodeStill encodes a single frame. JPEG sources stay JPEG and everything
+// else becomes PNG, so resized GIFs lose their animation.
+func EncodeStill(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// StillContentType is the content type EncodeStill produces for format.
+func StillContentType(format string) string {
+	if format == "jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// Fit scales width and height down to fit inside a maxDim square, keeping the
+// aspect ratio. Images that already fit are left alone.
+func Fit(width, height, maxDim int) (int, int) {
+	if width <= maxDim && height <= maxDim {
+		return width, height
+	}
+	if width >= height {
+		return maxDim, max(1, (height*maxDim+width/2)/width)
+	}
+	return max(1, (width*maxDim+height/2)/height), maxDim
+}
+
+// Resize shrinks img to fit inside a maxDim square by averaging the source
+// pixels that fall in each destination pixel. It never enlarges.
+func Resize(img image.Image, maxDim int) image.Image {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	w, h := Fit(sw, sh, maxDim)
+	if w == sw && h == sh {
+		return img
+	}
+
+	src := toRGBA(img)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					bl += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4]
+			d[0] = uint8(r / n)
+			d[1] = uint8(g / n)
+			d[2] = uint8(bl / n)
+			d[3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// toRGBA copies img into a zero-based RGBA image. Premultiplied alpha keeps
+// transparent pixels from bleeding their colour when averaged.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
@@ -0,0 +1,152 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// withOrientation inserts an EXIF block carrying only an orientation tag
+// right after the JPEG's start-of-image marker.
+func withOrientation(t *testing.T, jpg []byte, orientation byte) []byte {
+	t.Helper()
+	exif := []byte("Exif\x00\x00" +
+		"MM\x00\x2a\x00\x00\x00\x08" +
+		"\x00\x01" +
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00" + string([]byte{orientation}) + "\x00\x00" +
+		"\x00\x00\x00\x00")
+	segment := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	return append(append(append([]byte{}, jpg[:2]...), segment...), jpg[2:]...)
+}
+
+func TestDecode_RejectsTooManyPixels(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, solid(10, 10, color.White)); err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	if _, err := Decode(buf.Bytes(), 50); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("expected ErrTooManyPixels, got %v", err)
+	}
+	if _, err := Decode(buf.Bytes(), 100); err != nil {
+		t.Errorf("expected image at the limit to decode, got %v", err)
+	}
+}
+
+func TestDecode_RejectsGIFWithTooManyFrames(t *testing.T) {
+	frame := image.NewPaletted(image.Rect(0, 0, 10, 10), color.Palette{color.Black, color.White})
+	g := &gif.GIF{}
+	for i := 0; i < 1000; i++ {
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 0)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatalf("EncodeAll returned error: %v", err)
+	}
+
+	if frames, err := gifFrames(buf.Bytes(), 5000); err != nil || frames != 1000 {
+		t.Errorf("expected 1000 frames, got %d, %v", frames, err)
+	}
+	if _, err := Decode(buf.Bytes(), 99999); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("expected ErrTooManyPixels, got %v", err)
+	}
+	im, err := Decode(buf.Bytes(), 100000)
+	if err != nil {
+		t.Fatalf("expected animation at the limit to decode, got %v", err)
+	}
+	if len(im.GIF.Image) != 1000 {
+		t.Errorf("expected 1000 frames, got %d", len(im.GIF.Image))
+	}
+}
+
+func TestDecode_AppliesOrientationAndEncodeStripsExif(t *testing.T) {
+	src := solid(4, 2, color.White)
+	src.Set(0, 0, color.Black)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+	data := withOrientation(t, buf.Bytes(), 6)
+
+	if got := exifOrientation(data); got != 6 {
+		t.Fatalf("expected orientation 6, got %d", got)
+	}
+
+	im, err := Decode(data, 1000)
+	if err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if b := im.Image.Bounds(); b.Dx() != 2 || b.Dy() != 4 {
+		t.Fatalf("expected rotated image to be 2x4, got %dx%d", b.Dx(), b.Dy())
+	}
+	// Rotating clockwise moves the top-left pixel to the top-right.
+	if r, _, _, _ := im.Image.At(1, 0).RGBA(); r > 0x4000 {
+		t.Errorf("expected dark pixel at top right after rotation")
+	}
+
+	out, err := im.Encode()
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+	if bytes.Contains(out, []byte("Exif")) {
+		t.Errorf("expected EXIF to be stripped")
+	}
+	if got := exifOrientation(out); got != 1 {
+		t.Errorf("expected re-encoded image to be upright, got orientation %d", got)
+	}
+}
+
+func TestResize(t *testing.T) {
+	img := Resize(solid(400, 100, color.RGBA{R: 200, A: 255}), 100)
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 25 {
+		t.Fatalf("expected 100x25, got %dx%d", b.Dx(), b.Dy())
+	}
+	if r, _, _, _ := img.At(50, 10).RGBA(); r>>8 != 200 {
+		t.Errorf("expected averaged colour to be preserved, got red %d", r>>8)
+	}
+
+	small := solid(10, 10, color.White)
+	if Resize(small, 100) != image.Image(small) {
+		t.Errorf("expected images that fit to be returned unchanged")
+	}
+}
+
+func TestBlurhash(t *testing.T) {
+	got, err := Blurhash(solid(32, 24, color.Black), 4, 3)
+	if err != nil {
+		t.Fatalf("Blurhash returned error: %v", err)
+	}
+	// Black has no energy in any frequency.
+	if want := "L00000" + strings.Repeat("fQ", 11); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	got, err = Blurhash(solid(32, 24, color.White), 4, 3)
+	if err != nil {
+		t.Fatalf("Blurhash returned error: %v", err)
+	}
+	if len(got) != 28 || got[2:6] != "TSUA" {
+		t.Errorf("expected a 28 character hash with a white average, got %s", got)
+	}
+
+	if _, err := Blurhash(solid(1, 1, color.Black), 0, 3); err == nil {
+		t.Errorf("expected invalid component count to be rejected")
+	}
+}
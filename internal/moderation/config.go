@@ -0,0 +1,166 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// RuleConfig is one entry of a rule file. Which of the type specific fields
+// are used depends on Type: "words", "regex", "links" or "repeated".
+type RuleConfig struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Action  Action   `json:"action"`
+	Words   []string `json:"words,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+	Allow   []string `json:"allow,omitempty"`
+	Max     int      `json:"max,omitempty"`
+}
+
+type Config struct {
+	Rules []RuleConfig `json:"rules"`
+}
+
+// Default is used when no rule file is configured. It masks the words the
+// original filter did.
+var Default = Config{
+	Rules: []RuleConfig{
+		{
+			Name:   "profanity",
+			Type:   "words",
+			Action: ActionMask,
+			Words:  []string{"kerfuffle*", "sharbert*", "fornax*"},
+		},
+	},
+}
+
+func (c Config) Build() (*Pipeline, error) {
+	rules := []Rule{}
+	for i, rc := range c.Rules {
+		if rc.Name == "" {
+			rc.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		switch rc.Action {
+		case ActionMask, ActionReject, ActionFlag:
+		default:
+			return nil, fmt.Errorf("%s: unknown action %q", rc.Name, rc.Action)
+		}
+
+		switch rc.Type {
+		case "words":
+			rules = append(rules, NewWordList(rc.Name, rc.Action, rc.Words))
+		case "regex":
+			r, err := NewRegex(rc.Name, rc.Action, rc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rc.Name, err)
+			}
+			rules = append(rules, r)
+		case "links":
+			rules = append(rules, NewLinks(rc.Name, rc.Action, rc.Allow))
+		case "repeated":
+			if rc.Max < 1 {
+				return nil, fmt.Errorf("%s: max must be at least 1", rc.Name)
+			}
+			rules = append(rules, NewRepeatedChars(rc.Name, rc.Action, rc.Max))
+		default:
+			return nil, fmt.Errorf("%s: unknown rule type %q", rc.Name, rc.Type)
+		}
+	}
+	return NewPipeline(rules...), nil
+}
+
+func Load(path string) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Config{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("moderation rules %s: %w", path, err)
+	}
+
+	p, err := c.Build()
+	if err != nil {
+		return nil, fmt.Errorf("moderation rules %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Moderator holds the current pipeline and swaps it out when the rule file
+// changes. Checks in flight keep using the pipeline they started with.
+type Moderator struct {
+	path     string
+	pipeline atomic.Pointer[Pipeline]
+	modTime  time.Time
+}
+
+// NewModerator loads the rule file at path, or the default rules when path is
+// empty.
+func NewModerator(path string) (*Moderator, error) {
+	m := &Moderator{path: path}
+	if path == "" {
+		p, err := Default.Build()
+		if err != nil {
+			return nil, err
+		}
+		m.pipeline.Store(p)
+		return m, nil
+	}
+
+	if _, err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Moderator) Check(text string) Result {
+	return m.pipeline.Load().Check(text)
+}
+
+// Reload reads the rule file again if it changed since the last load. A file
+// that fails to parse leaves the current rules in place.
+func (m *Moderator) Reload() (bool, error) {
+	info, err := os.Stat(m.path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(m.modTime) && m.pipeline.Load() != nil {
+		return false, nil
+	}
+
+	p, err := Load(m.path)
+	if err != nil {
+		return false, err
+	}
+	m.pipeline.Store(p)
+	m.modTime = info.ModTime()
+	return true, nil
+}
+
+// Watch polls the rule file every interval until ctx is done. It does nothing
+// for a Moderator using the default rules.
+func (m *Moderator) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	if m.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := m.Reload(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package moderation checks user content against a chain of rules. Each rule
+// finds spans of the text and says what to do about them: mask the span,
+// reject the whole text, or let it through but flag it for review.
+package moderation
+
+import (
+	"sort"
+	"strings"
+)
+
+type Action string
+
+const (
+	ActionMask   Action = "mask"
+	ActionReject Action = "reject"
+	ActionFlag   Action = "flag"
+)
+
+const maskText = "****"
+
+// Span is a byte range of the checked text.
+type Span struct {
+	Start int
+	End   int
+}
+
+type Rule interface {
+	Name() string
+	Action() Action
+	Find(text string) []Span
+}
+
+type Violation struct {
+	Rule   string `json:"rule"`
+	Action Action `json:"action"`
+	Match  string `json:"match"`
+}
+
+type Result struct {
+	// Text is the input with every masked span replaced.
+	Text       string
+	Violations []Violation
+}
+
+func (r Result) Rejected() bool {
+	return r.has(ActionReject)
+}
+
+func (r Result) Flagged() bool {
+	return r.has(ActionFlag)
+}
+
+func (r Result) has(action Action) bool {
+	for _, v := range r.Violations {
+		if v.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+type Pipeline struct {
+	rules []Rule
+}
+
+func NewPipeline(rules ...Rule) *Pipeline {
+	return &Pipeline{rules: rules}
+}
+
+// Check runs every rule against the original text, so one rule's masking
+// can't hide content from the rules after it.
+func (p *Pipeline) Check(text string) Result {
+	result := Result{Text: text}
+	masks := []Span{}
+
+	for _, rule := range p.rules {
+		for _, span := range rule.Find(text) {
+			result.Violations = append(result.Violations, Violation{
+				Rule:   rule.Name(),
+				Action: rule.Action(),
+				Match:  text[span.Start:span.End],
+			})
+			if rule.Action() == ActionMask {
+				masks = append(masks, span)
+			}
+		}
+	}
+
+	if len(masks) > 0 {
+		result.Text = applyMasks(text, masks)
+	}
+	return result
+}
+
+// applyMasks replaces each span with maskText. Overlapping spans are merged
+// first so they produce a single mask.
+func applyMasks(text string, masks []Span) string {
+	sort.Slice(masks, func(i, j int) bool { return masks[i].Start < masks[j].Start })
+
+	merged := []Span{masks[0]}
+	for _, m := range masks[1:] {
+		last := &merged[len(merged)-1]
+		if m.Start <= last.End {
+			last.End = max(last.End, m.End)
+			continue
+		}
+		merged = append(merged, m)
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, m := range merged {
+		b.WriteString(text[pos:m.Start])
+		b.WriteString(maskText)
+		pos = m.End
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
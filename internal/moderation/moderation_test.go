@@ -0,0 +1,141 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDefaultMasksWords(t *testing.T) {
+	p, err := Default.Build()
+	if err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+
+	cases := map[string]string{
+		"I had something interesting for breakfast": "I had something interesting for breakfast",
+		"What a kerfuffle!":                         "What a ****!",
+		"KERFUFFLE and Sharbert":                    "**** and ****",
+		"so many kerfuffles":                        "so many ****",
+		"unkerfuffled":                              "unkerfuffled",
+	}
+	for in, want := range cases {
+		if got := p.Check(in).Text; got != want {
+			t.Errorf("Check(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWordListFoldsUnicode(t *testing.T) {
+	p := NewPipeline(NewWordList("words", ActionFlag, []string{"στρες", "straße"}))
+
+	if r := p.Check("ΣΤΡΕΣ"); !r.Flagged() {
+		t.Errorf("expected upper case Greek to match")
+	}
+	if r := p.Check("STRAẞE"); !r.Flagged() {
+		t.Errorf("expected capital sharp s to match")
+	}
+	if r := p.Check("strasse"); r.Flagged() {
+		t.Errorf("did not expect full case folding")
+	}
+}
+
+func TestLinks(t *testing.T) {
+	p := NewPipeline(NewLinks("links", ActionReject, []string{"chirpy.dev"}))
+
+	if r := p.Check("see https://docs.chirpy.dev/api."); r.Rejected() {
+		t.Errorf("expected subdomain of allowed domain to pass: %+v", r.Violations)
+	}
+	r := p.Check("go to http://evil.example:8080/x, now")
+	if !r.Rejected() {
+		t.Fatalf("expected link to be rejected")
+	}
+	if r.Violations[0].Match != "http://evil.example:8080/x" {
+		t.Errorf("unexpected match %q", r.Violations[0].Match)
+	}
+	if r := p.Check("https://chirpy.dev.evil.example"); !r.Rejected() {
+		t.Errorf("expected lookalike host to be rejected")
+	}
+}
+
+func TestRepeatedChars(t *testing.T) {
+	p := NewPipeline(NewRepeatedChars("spam", ActionMask, 3))
+
+	if got := p.Check("cooool nooOOoo").Text; got != "c****l n****" {
+		t.Errorf("unexpected text %q", got)
+	}
+	if got := p.Check("wait      what").Text; got != "wait      what" {
+		t.Errorf("expected whitespace runs to be ignored, got %q", got)
+	}
+}
+
+func TestOverlappingMasks(t *testing.T) {
+	re, err := NewRegex("regex", ActionMask, `fornax \w+`)
+	if err != nil {
+		t.Fatalf("NewRegex returned error: %v", err)
+	}
+	p := NewPipeline(NewWordList("words", ActionMask, []string{"fornax"}), re)
+
+	if got := p.Check("a fornax cluster here").Text; got != "a **** here" {
+		t.Errorf("unexpected text %q", got)
+	}
+}
+
+func TestBuildRejectsBadRules(t *testing.T) {
+	bad := []Config{
+		{Rules: []RuleConfig{{Type: "words", Action: "delete"}}},
+		{Rules: []RuleConfig{{Type: "magic", Action: ActionFlag}}},
+		{Rules: []RuleConfig{{Type: "regex", Action: ActionFlag, Pattern: "("}}},
+		{Rules: []RuleConfig{{Type: "repeated", Action: ActionFlag}}},
+	}
+	for _, c := range bad {
+		if _, err := c.Build(); err == nil {
+			t.Errorf("expected error for %+v", c.Rules[0])
+		}
+	}
+}
+
+func TestModeratorReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	write := func(body string, mod time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatalf("WriteFile returned error: %v", err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatalf("Chtimes returned error: %v", err)
+		}
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write(`{"rules": [{"type": "words", "action": "reject", "words": ["spam"]}]}`, start)
+
+	m, err := NewModerator(path)
+	if err != nil {
+		t.Fatalf("NewModerator returned error: %v", err)
+	}
+	if !m.Check("buy spam").Rejected() {
+		t.Fatalf("expected initial rules to reject")
+	}
+
+	if changed, err := m.Reload(); err != nil || changed {
+		t.Errorf("expected no reload for unchanged file, got %v %v", changed, err)
+	}
+
+	write(`{"rules": [`, start.Add(time.Minute))
+	if _, err := m.Reload(); err == nil {
+		t.Errorf("expected error for broken rule file")
+	}
+	if !m.Check("buy spam").Rejected() {
+		t.Errorf("expected broken file to keep the old rules")
+	}
+
+	write(`{"rules": [{"type": "words", "action": "flag", "words": ["ham"]}]}`, start.Add(2*time.Minute))
+	if changed, err := m.Reload(); err != nil || !changed {
+		t.Fatalf("expected reload, got %v %v", changed, err)
+	}
+	if m.Check("buy spam").Rejected() || !m.Check("buy ham").Flagged() {
+		t.Errorf("expected new rules after reload")
+	}
+}
@@ -0,0 +1,214 @@
+package moderation
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// WordList matches whole words regardless of case. An entry ending in "*"
+// also matches words that start with it, so "kerfuffle*" catches
+// "kerfuffles" without also catching every word that merely contains it.
+type WordList struct {
+	name     string
+	action   Action
+	words    map[string]bool
+	prefixes []string
+}
+
+func NewWordList(name string, action Action, words []string) *WordList {
+	w := &WordList{
+		name:   name,
+		action: action,
+		words:  map[string]bool{},
+	}
+	for _, word := range words {
+		if prefix, ok := strings.CutSuffix(word, "*"); ok {
+			w.prefixes = append(w.prefixes, fold(prefix))
+			continue
+		}
+		w.words[fold(word)] = true
+	}
+	return w
+}
+
+func (w *WordList) Name() string   { return w.name }
+func (w *WordList) Action() Action { return w.action }
+
+func (w *WordList) Find(text string) []Span {
+	spans := []Span{}
+	for _, span := range words(text) {
+		word := fold(text[span.Start:span.End])
+		if w.words[word] || w.hasPrefix(word) {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func (w *WordList) hasPrefix(word string) bool {
+	for _, p := range w.prefixes {
+		if strings.HasPrefix(word, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// words splits text on anything that isn't a letter, digit or combining mark.
+func words(text string) []Span {
+	spans := []Span{}
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+		if inWord && start < 0 {
+			start = i
+		}
+		if !inWord && start >= 0 {
+			spans = append(spans, Span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, Span{start, len(text)})
+	}
+	return spans
+}
+
+// fold maps every rune to a canonical member of its Unicode simple case
+// folding orbit, so "KERFUFFLE", "Kerfuffle" and "kerfuffle" compare equal,
+// as do the different forms of letters like the Greek sigma.
+func fold(s string) string {
+	return strings.Map(foldRune, s)
+}
+
+func foldRune(r rune) rune {
+	least := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		least = min(least, f)
+	}
+	return least
+}
+
+type Regex struct {
+	name   string
+	action Action
+	re     *regexp.Regexp
+}
+
+func NewRegex(name string, action Action, pattern string) (*Regex, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Regex{name: name, action: action, re: re}, nil
+}
+
+func (r *Regex) Name() string   { return r.name }
+func (r *Regex) Action() Action { return r.action }
+
+func (r *Regex) Find(text string) []Span {
+	spans := []Span{}
+	for _, m := range r.re.FindAllStringIndex(text, -1) {
+		spans = append(spans, Span{m[0], m[1]})
+	}
+	return spans
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// Links matches links to any host outside the allowlist. Subdomains of an
+// allowed domain are allowed too.
+type Links struct {
+	name    string
+	action  Action
+	allowed []string
+}
+
+func NewLinks(name string, action Action, allowed []string) *Links {
+	l := &Links{name: name, action: action}
+	for _, d := range allowed {
+		l.allowed = append(l.allowed, strings.ToLower(strings.TrimPrefix(d, ".")))
+	}
+	return l
+}
+
+func (l *Links) Name() string   { return l.name }
+func (l *Links) Action() Action { return l.action }
+
+func (l *Links) Find(text string) []Span {
+	spans := []Span{}
+	for _, m := range linkPattern.FindAllStringIndex(text, -1) {
+		link := strings.TrimRight(text[m[0]:m[1]], ".,;:!?)]}'")
+		if !l.allows(linkHost(link)) {
+			spans = append(spans, Span{m[0], m[0] + len(link)})
+		}
+	}
+	return spans
+}
+
+func (l *Links) allows(host string) bool {
+	for _, d := range l.allowed {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func linkHost(link string) string {
+	if i := strings.Index(link, "://"); i >= 0 {
+		link = link[i+3:]
+	}
+	if i := strings.IndexAny(link, "/?#"); i >= 0 {
+		link = link[:i]
+	}
+	if i := strings.LastIndex(link, "@"); i >= 0 {
+		link = link[i+1:]
+	}
+	if i := strings.LastIndex(link, ":"); i >= 0 {
+		link = link[:i]
+	}
+	return strings.ToLower(strings.TrimSuffix(link, "."))
+}
+
+// RepeatedChars matches runs of the same character, ignoring case, that are
+// longer than Max. Whitespace runs are left alone.
+type RepeatedChars struct {
+	name   string
+	action Action
+	max    int
+}
+
+func NewRepeatedChars(name string, action Action, limit int) *RepeatedChars {
+	return &RepeatedChars{name: name, action: action, max: limit}
+}
+
+func (c *RepeatedChars) Name() string   { return c.name }
+func (c *RepeatedChars) Action() Action { return c.action }
+
+func (c *RepeatedChars) Find(text string) []Span {
+	spans := []Span{}
+	start, run := 0, 0
+	var prev rune = -1
+
+	flush := func(end int) {
+		if run > c.max && !unicode.IsSpace(prev) {
+			spans = append(spans, Span{start, end})
+		}
+	}
+
+	for i, r := range text {
+		r = foldRune(r)
+		if r == prev {
+			run++
+			continue
+		}
+		flush(i)
+		start, run, prev = i, 1, r
+	}
+	if text != "" {
+		flush(len(text))
+	}
+	return spans
+}
@@ -0,0 +1,169 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type stubProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	issuer   string
+	clientID string
+
+	// Values the token endpoint expects and puts in the ID token.
+	code      string
+	challenge string
+	nonce     string
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+
+	s := &stubProvider{key: key, clientID: "chirpy"}
+	mux := http.NewServeMux()
+	s.server = httptest.NewServer(mux)
+	s.issuer = s.server.URL
+	t.Cleanup(s.server.Close)
+
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                s.issuer,
+			AuthorizationEndpoint: s.issuer + "/authorize",
+			TokenEndpoint:         s.issuer + "/token",
+			JWKSURI:               s.issuer + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks{Keys: []jwk{{
+			Kty: "RSA",
+			Kid: "test",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != s.code || CodeChallengeS256(r.PostForm.Get("code_verifier")) != s.challenge {
+			w.WriteHeader(400)
+			return
+		}
+		json.NewEncoder(w).Encode(TokenResponse{IDToken: s.idToken(t, s.nonce, time.Minute)})
+	})
+
+	return s
+}
+
+func (s *stubProvider) idToken(t *testing.T, nonce string, expiresIn time.Duration) string {
+	t.Helper()
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   "external-user-1",
+			Audience:  jwt.ClaimStrings{s.clientID},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		},
+		Nonce:         nonce,
+		Email:         "walt@example.com",
+		EmailVerified: true,
+	})
+	tok.Header["kid"] = "test"
+
+	signed, err := tok.SignedString(s.key)
+	if err != nil {
+		t.Fatalf("SignedString returned error: %v", err)
+	}
+	return signed
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	stub := newStubProvider(t)
+	ctx := context.Background()
+
+	p := NewProvider(Config{
+		Issuer:      stub.issuer,
+		ClientID:    stub.clientID,
+		RedirectURL: "http://localhost:8080/api/auth/oidc/callback",
+	})
+
+	verifier, err := GenerateCodeVerifier()
+	if err != nil {
+		t.Fatalf("GenerateCodeVerifier returned error: %v", err)
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", CodeChallengeS256(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL returned error: %v", err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("could not parse auth url: %v", err)
+	}
+	q := u.Query()
+	if q.Get("state") != "state-1" || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+
+	stub.code = "code-1"
+	stub.challenge = q.Get("code_challenge")
+	stub.nonce = q.Get("nonce")
+
+	tokens, err := p.Exchange(ctx, "code-1", verifier)
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+
+	claims, err := p.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken returned error: %v", err)
+	}
+	if claims.Subject != "external-user-1" || claims.Email != "walt@example.com" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	if _, err := p.Exchange(ctx, "code-1", "wrong verifier"); err == nil {
+		t.Errorf("expected exchange with wrong code verifier to fail")
+	}
+}
+
+func TestVerifyIDToken_Rejects(t *testing.T) {
+	stub := newStubProvider(t)
+	ctx := context.Background()
+
+	p := NewProvider(Config{Issuer: stub.issuer, ClientID: stub.clientID})
+
+	if _, err := p.VerifyIDToken(ctx, stub.idToken(t, "nonce-1", time.Minute), "other nonce"); err == nil {
+		t.Errorf("expected nonce mismatch to fail")
+	}
+
+	if _, err := p.VerifyIDToken(ctx, stub.idToken(t, "nonce-1", -time.Minute), "nonce-1"); err == nil {
+		t.Errorf("expected expired token to fail")
+	}
+
+	other := NewProvider(Config{Issuer: stub.issuer, ClientID: "someone-else"})
+	if _, err := other.VerifyIDToken(ctx, stub.idToken(t, "nonce-1", time.Minute), "nonce-1"); err == nil {
+		t.Errorf("expected wrong audience to fail")
+	}
+}
@@ -0,0 +1,113 @@
+package pubsub
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryBroker delivers events to subscribers in this process.
+type MemoryBroker struct {
+	epoch string
+
+	bufferSize  int
+	historySize int
+
+	mu      sync.Mutex
+	lastID  uint64
+	history []Event
+	subs    map[*Subscription]struct{}
+}
+
+// NewMemoryBroker returns a broker that remembers the last historySize events
+// for resuming subscribers and lets each subscriber fall up to bufferSize
+// events behind before dropping it.
+func NewMemoryBroker(historySize, bufferSize int) *MemoryBroker {
+	return &MemoryBroker{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		bufferSize:  bufferSize,
+		historySize: historySize,
+		subs:        map[*Subscription]struct{}{},
+	}
+}
+
+func (b *MemoryBroker) Epoch() string {
+	return b.epoch
+}
+
+// Publish never blocks: subscribers with a full buffer are dropped rather
+// than holding up everyone else.
+func (b *MemoryBroker) Publish(topics []string, eventType string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e := Event{ID: b.lastID, Topics: topics, Type: eventType, Data: data}
+
+	b.history = append(b.history, e)
+	if len(b.history) > b.historySize {
+		b.history = slices.Delete(b.history, 0, len(b.history)-b.historySize)
+	}
+
+	for s := range b.subs {
+		if !s.matches(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			s.err = ErrSlowSubscriber
+			b.remove(s)
+		}
+	}
+	return nil
+}
+
+// Resync tells every subscriber that events may have been lost.
+func (b *MemoryBroker) Resync() {
+	b.Publish(nil, EventResync, []byte("{}"))
+}
+
+func (b *MemoryBroker) Subscribe(topics []string, after uint64) (s *Subscription, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s = &Subscription{topics: topics}
+	complete = true
+
+	var replay []Event
+	if after > 0 {
+		if after > b.lastID || (len(b.history) > 0 && b.history[0].ID > after+1) {
+			complete = false
+		}
+		for _, e := range b.history {
+			if e.ID > after && s.matches(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	s.c = make(chan Event, b.bufferSize+len(replay))
+	s.C = s.c
+	for _, e := range replay {
+		s.c <- e
+	}
+
+	b.subs[s] = struct{}{}
+	return s, complete
+}
+
+func (b *MemoryBroker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(s)
+}
+
+func (b *MemoryBroker) remove(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.c)
+}
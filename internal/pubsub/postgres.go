@@ -0,0 +1,141 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Postgres rejects NOTIFY payloads of 8000 bytes or more.
+const maxNotifyPayload = 7999
+
+// listenerPingInterval is how often an idle listener checks its connection,
+// so a silently dropped one is noticed and re-established.
+const listenerPingInterval = 90 * time.Second
+
+type notification struct {
+	Origin string          `json:"origin"`
+	Topics []string        `json:"topics"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// PostgresBroker relays events between every instance listening on the same
+// Postgres channel. Each instance still fans events out to its own
+// subscribers with a MemoryBroker, so event IDs and replay stay per
+// instance: a client that reconnects to another instance is told to resync.
+type PostgresBroker struct {
+	*MemoryBroker
+
+	db       *sql.DB
+	channel  string
+	origin   string
+	listener *pq.Listener
+}
+
+// NewPostgresBroker relays events through channel. Publishing uses db;
+// listening needs a connection of its own, opened from dbURL. Call Run to
+// start receiving events from other instances.
+func NewPostgresBroker(db *sql.DB, dbURL, channel string, local *MemoryBroker) *PostgresBroker {
+	origin := make([]byte, 8)
+	rand.Read(origin)
+
+	return &PostgresBroker{
+		MemoryBroker: local,
+		db:           db,
+		channel:      channel,
+		origin:       hex.EncodeToString(origin),
+		listener:     pq.NewListener(dbURL, time.Second, time.Minute, nil),
+	}
+}
+
+// Publish delivers the event to this instance's subscribers straight away
+// and sends it to the other instances with NOTIFY. An event too large for a
+// NOTIFY payload can't be relayed, so the other instances are told to resync
+// instead.
+func (b *PostgresBroker) Publish(topics []string, eventType string, data []byte) error {
+	b.MemoryBroker.Publish(topics, eventType, data)
+
+	payload, err := json.Marshal(notification{
+		Origin: b.origin,
+		Topics: topics,
+		Type:   eventType,
+		Data:   data,
+	})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		payload, err = json.Marshal(notification{
+			Origin: b.origin,
+			Type:   EventResync,
+			Data:   json.RawMessage("{}"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = b.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", b.channel, string(payload))
+	return err
+}
+
+// Run listens for events from other instances until ctx is done. The
+// listener reconnects on its own; since anything sent while it was away is
+// lost, every subscriber is told to resync when it comes back.
+func (b *PostgresBroker) Run(ctx context.Context, onError func(error)) {
+	defer b.listener.Close()
+
+	if err := b.listener.Listen(b.channel); err != nil {
+		onError(err)
+	}
+
+	ping := time.NewTicker(listenerPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-b.listener.Notify:
+			if n == nil {
+				b.Resync()
+				continue
+			}
+			if err := b.receive(n.Extra); err != nil {
+				onError(err)
+			}
+		case <-ping.C:
+			// Ping blocks while the listener is reconnecting.
+			go func() {
+				if err := b.listener.Ping(); err != nil {
+					onError(err)
+				}
+			}()
+		}
+	}
+}
+
+func (b *PostgresBroker) receive(payload string) error {
+	var n notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return fmt.Errorf("invalid notification: %w", err)
+	}
+	// Our own events were delivered locally when they were published.
+	if n.Origin == b.origin {
+		return nil
+	}
+	if n.Type == EventResync {
+		b.Resync()
+		return nil
+	}
+	return b.MemoryBroker.Publish(n.Topics, n.Type, n.Data)
+}
@@ -0,0 +1,68 @@
+// Package pubsub fans events out to long-lived client connections. A
+// MemoryBroker reaches subscribers in this process; a PostgresBroker also
+// relays events between instances through Postgres LISTEN/NOTIFY.
+package pubsub
+
+import (
+	"errors"
+	"slices"
+)
+
+// ErrSlowSubscriber is the reason a subscription was closed when it fell so
+// far behind that its buffer filled up.
+var ErrSlowSubscriber = errors.New("subscriber fell behind")
+
+// EventResync is sent to every subscriber when events may have been lost,
+// for example while a PostgresBroker was reconnecting.
+const EventResync = "resync"
+
+type Event struct {
+	// ID increases by one for every event a broker delivers. IDs are local
+	// to one broker and only comparable within its Epoch.
+	ID     uint64
+	Topics []string
+	Type   string
+	// Data is a JSON document.
+	Data []byte
+}
+
+type Broker interface {
+	// Publish sends an event to the subscribers of any of its topics.
+	Publish(topics []string, eventType string, data []byte) error
+	// Subscribe starts a subscription to topics, first replaying what it
+	// remembers since the event with ID after when that's non-zero.
+	// complete reports whether nothing since then was forgotten.
+	Subscribe(topics []string, after uint64) (s *Subscription, complete bool)
+	// Unsubscribe ends a subscription. It's safe to call more than once.
+	Unsubscribe(s *Subscription)
+	// Epoch tells this broker's event IDs apart from another's, including
+	// its own before a restart.
+	Epoch() string
+}
+
+type Subscription struct {
+	// C is closed when the subscription ends; Err then says why.
+	C <-chan Event
+
+	c      chan Event
+	topics []string
+	err    error
+}
+
+func (s *Subscription) matches(e Event) bool {
+	if e.Type == EventResync {
+		return true
+	}
+	for _, topic := range e.Topics {
+		if slices.Contains(s.topics, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns ErrSlowSubscriber once the broker has dropped the
+// subscription, and nil otherwise. Only call it after C is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
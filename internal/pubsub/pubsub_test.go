@@ -0,0 +1,141 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+func drain(s *Subscription) []Event {
+	var events []Event
+	for {
+		select {
+		case e, ok := <-s.C:
+			if !ok {
+				return events
+			}
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestPublishTopics(t *testing.T) {
+	b := NewMemoryBroker(10, 10)
+	s, _ := b.Subscribe([]string{"a", "b"}, 0)
+	defer b.Unsubscribe(s)
+
+	b.Publish([]string{"a"}, "one", nil)
+	b.Publish([]string{"c"}, "two", nil)
+	b.Publish([]string{"b", "c"}, "three", nil)
+
+	events := drain(s)
+	if len(events) != 2 || events[0].Type != "one" || events[1].Type != "three" {
+		t.Fatalf("expected events one and three, got %+v", events)
+	}
+	if events[0].ID != 1 || events[1].ID != 3 {
+		t.Errorf("expected ids 1 and 3, got %d and %d", events[0].ID, events[1].ID)
+	}
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	b := NewMemoryBroker(3, 10)
+	for i := 0; i < 5; i++ {
+		b.Publish([]string{"a"}, "event", nil)
+	}
+
+	s, complete := b.Subscribe([]string{"a"}, 3)
+	if !complete {
+		t.Errorf("expected replay from inside the history to be complete")
+	}
+	if events := drain(s); len(events) != 2 || events[0].ID != 4 {
+		t.Errorf("expected events 4 and 5, got %+v", events)
+	}
+
+	s, complete = b.Subscribe([]string{"a"}, 1)
+	if complete {
+		t.Errorf("expected replay from before the history to be incomplete")
+	}
+	if events := drain(s); len(events) != 3 {
+		t.Errorf("expected the 3 remembered events, got %d", len(events))
+	}
+
+	if _, complete := b.Subscribe([]string{"a"}, 99); complete {
+		t.Errorf("expected an id from the future to be incomplete")
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	b := NewMemoryBroker(10, 2)
+	slow, _ := b.Subscribe([]string{"a"}, 0)
+	fast, _ := b.Subscribe([]string{"a"}, 0)
+	defer b.Unsubscribe(fast)
+
+	for i := 0; i < 3; i++ {
+		b.Publish([]string{"a"}, "event", nil)
+		drain(fast)
+	}
+
+	if events := drain(slow); len(events) != 2 {
+		t.Errorf("expected the 2 buffered events, got %d", len(events))
+	}
+	if _, ok := <-slow.C; ok {
+		t.Fatalf("expected the slow subscription to be closed")
+	}
+	if slow.Err() != ErrSlowSubscriber {
+		t.Errorf("expected ErrSlowSubscriber, got %v", slow.Err())
+	}
+
+	// Unsubscribing after being dropped is fine.
+	b.Unsubscribe(slow)
+}
+
+func TestResync(t *testing.T) {
+	b := NewMemoryBroker(10, 10)
+	s, _ := b.Subscribe([]string{"a"}, 0)
+	defer b.Unsubscribe(s)
+
+	b.Resync()
+
+	if events := drain(s); len(events) != 1 || events[0].Type != EventResync {
+		t.Errorf("expected a resync event whatever the topics, got %+v", events)
+	}
+}
+
+func TestPostgresBrokerReceive(t *testing.T) {
+	b := &PostgresBroker{MemoryBroker: NewMemoryBroker(10, 10), origin: "self"}
+	s, _ := b.Subscribe([]string{"a"}, 0)
+	defer b.Unsubscribe(s)
+
+	if err := b.receive(`{"origin":"self","topics":["a"],"type":"mine","data":{}}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.receive(`{"origin":"other","topics":["a"],"type":"theirs","data":{"n":1}}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.receive(`not json`); err == nil {
+		t.Errorf("expected a malformed payload to be rejected")
+	}
+
+	events := drain(s)
+	if len(events) != 1 || events[0].Type != "theirs" {
+		t.Fatalf("expected only the other instance's event, got %+v", events)
+	}
+	if string(events[0].Data) != `{"n":1}` {
+		t.Errorf("expected the data to survive the trip, got %s", events[0].Data)
+	}
+}
+
+func TestPostgresBrokerReceiveResync(t *testing.T) {
+	b := &PostgresBroker{MemoryBroker: NewMemoryBroker(10, 10), origin: "self"}
+	s, _ := b.Subscribe([]string{"a"}, 0)
+	defer b.Unsubscribe(s)
+
+	// What an instance sends in place of an event too large to relay.
+	if err := b.receive(`{"origin":"other","topics":null,"type":"resync","data":{}}`); err != nil {
+		t.Fatal(err)
+	}
+
+	if events := drain(s); len(events) != 1 || events[0].Type != EventResync {
+		t.Errorf("expected a resync event, got %+v", events)
+	}
+}
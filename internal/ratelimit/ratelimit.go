@@ -0,0 +1,68 @@
+// Package ratelimit implements an in-process fixed window rate limiter.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type window struct {
+	start time.Time
+	count int
+}
+
+type Limiter struct {
+	period time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*window
+	calls   int
+}
+
+func New(period time.Duration) *Limiter {
+	return &Limiter{
+		period:  period,
+		now:     time.Now,
+		windows: map[string]*window{},
+	}
+}
+
+// Allow counts a hit against key and reports whether it is within limit for
+// the current window. When it isn't, retryAfter is the time until the window
+// resets. A limit of zero or less means unlimited.
+func (l *Limiter) Allow(key string, limit int) (ok bool, retryAfter time.Duration) {
+	if limit <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	l.calls++
+	if l.calls%1000 == 0 {
+		l.sweep(now)
+	}
+
+	w, found := l.windows[key]
+	if !found || now.Sub(w.start) >= l.period {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+
+	if w.count >= limit {
+		return false, w.start.Add(l.period).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.period {
+			delete(l.windows, key)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := New(time.Hour)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("user", 3); !ok {
+			t.Fatalf("expected hit %d to be allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := l.Allow("user", 3)
+	if ok {
+		t.Fatalf("expected hit over the limit to be rejected")
+	}
+	if retryAfter != time.Hour {
+		t.Errorf("expected retry after %v, got %v", time.Hour, retryAfter)
+	}
+
+	if ok, _ := l.Allow("other user", 3); !ok {
+		t.Errorf("expected limits to be per key")
+	}
+
+	now = now.Add(time.Hour)
+	if ok, _ := l.Allow("user", 3); !ok {
+		t.Errorf("expected a new window to reset the count")
+	}
+}
@@ -0,0 +1,107 @@
+// Package webhooks delivers signed event payloads to subscriber endpoints.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+)
+
+const (
+	EventChirpCreated  = "chirp.created"
+	EventChirpDeleted  = "chirp.deleted"
+	EventChirpRestored = "chirp.restored"
+	EventChirpUpdated  = "chirp.updated"
+)
+
+var SupportedEvents = []string{EventChirpCreated, EventChirpDeleted, EventChirpRestored, EventChirpUpdated}
+
+type Sender struct {
+	Client      *http.Client
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// NewSender returns a Sender whose client refuses to connect to loopback,
+// private and link-local addresses unless allowPrivate is set, so endpoints
+// can't be pointed at our own infrastructure.
+func NewSender(allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+				return fmt.Errorf("refusing to deliver webhook to %s", host)
+			}
+			return nil
+		}
+	}
+
+	return &Sender{
+		Client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		MaxAttempts: 8,
+		BaseBackoff: 30 * time.Second,
+		MaxBackoff:  6 * time.Hour,
+	}
+}
+
+// Send posts body to url, signed the same way Polka signs its webhooks to us.
+// Any non-2xx response is an error.
+func (s *Sender) Send(ctx context.Context, url, secret, eventType, deliveryID string, body []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set("Chirpy-Event", eventType)
+	req.Header.Set("Chirpy-Delivery", deliveryID)
+	req.Header.Set("Chirpy-Timestamp", timestamp)
+	req.Header.Set("Chirpy-Signature", "v1="+auth.SignWebhook(secret, timestamp, body))
+
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("endpoint responded with status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// Backoff returns how long to wait before retrying after the given number of
+// failed attempts.
+func (s *Sender) Backoff(attempts int) time.Duration {
+	d := s.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= s.MaxBackoff {
+			return s.MaxBackoff
+		}
+	}
+	return d
+}
@@ -0,0 +1,78 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+)
+
+func TestSend(t *testing.T) {
+	var gotBody []byte
+	var gotHeader http.Header
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header
+		w.WriteHeader(204)
+	}))
+	defer receiver.Close()
+
+	s := NewSender(true)
+	body := []byte(`{"type":"chirp.created"}`)
+
+	code, err := s.Send(context.Background(), receiver.URL, "secret", EventChirpCreated, "delivery-1", body)
+	if err != nil || code != 204 {
+		t.Fatalf("expected 204, got %d (err %v)", code, err)
+	}
+
+	if string(gotBody) != string(body) {
+		t.Errorf("receiver got body %q", gotBody)
+	}
+	if gotHeader.Get("Chirpy-Event") != EventChirpCreated || gotHeader.Get("Chirpy-Delivery") != "delivery-1" {
+		t.Errorf("unexpected headers %v", gotHeader)
+	}
+
+	err = auth.VerifyWebhookSignature(
+		[]string{"secret"},
+		gotHeader.Get("Chirpy-Timestamp"),
+		gotHeader.Get("Chirpy-Signature"),
+		gotBody,
+		time.Minute,
+		time.Now(),
+	)
+	if err != nil {
+		t.Errorf("expected receiver to verify signature, got %v", err)
+	}
+}
+
+func TestSend_Failures(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	}))
+	defer receiver.Close()
+
+	code, err := NewSender(true).Send(context.Background(), receiver.URL, "secret", EventChirpCreated, "delivery-1", []byte(`{}`))
+	if err == nil || code != 503 {
+		t.Errorf("expected 503 error, got %d (err %v)", code, err)
+	}
+
+	if _, err := NewSender(false).Send(context.Background(), receiver.URL, "secret", EventChirpCreated, "delivery-1", []byte(`{}`)); err == nil {
+		t.Errorf("expected delivery to loopback address to be refused")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	s := &Sender{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := s.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
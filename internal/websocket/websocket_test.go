@@ -0,0 +1,244 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// The example from RFC 6455 section 1.3.
+	if got := AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept key %q", got)
+	}
+}
+
+// clientFrame encodes a masked frame the way a client sends it.
+func clientFrame(fin bool, opcode int, payload []byte) []byte {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	out := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		out = append(out, 0x80|byte(n))
+	case n <= 0xFFFF:
+		out = append(out, 0x80|126)
+		out = binary.BigEndian.AppendUint16(out, uint16(n))
+	default:
+		out = append(out, 0x80|127)
+		out = binary.BigEndian.AppendUint64(out, uint64(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	out = append(out, mask...)
+	for i, b := range payload {
+		out = append(out, b^mask[i%4])
+	}
+	return out
+}
+
+// readServerFrame decodes one unmasked server frame.
+func readServerFrame(r io.Reader) (int, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	if head[1]&0x80 != 0 {
+		return 0, nil, errors.New("server frames must not be masked")
+	}
+	n := int(head[1] & 0x7F)
+	if n == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return int(head[0] & 0x0F), payload, nil
+}
+
+func pipe(t *testing.T) (*Conn, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	server.SetDeadline(time.Now().Add(5 * time.Second))
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	return newConn(server, bufio.NewReader(server)), client
+}
+
+func TestReadMessageFragmentedWithPing(t *testing.T) {
+	c, client := pipe(t)
+
+	go func() {
+		client.Write(clientFrame(false, OpText, []byte("hel")))
+		client.Write(clientFrame(true, OpPing, []byte("p")))
+		client.Write(clientFrame(true, OpContinuation, []byte("lo")))
+	}()
+
+	pong := make(chan []byte, 1)
+	go func() {
+		op, payload, err := readServerFrame(client)
+		if err != nil || op != OpPong {
+			t.Errorf("expected a pong, got opcode %d: %v", op, err)
+		}
+		pong <- payload
+	}()
+
+	op, data, err := c.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if op != OpText || string(data) != "hello" {
+		t.Errorf("expected text hello, got %d %q", op, data)
+	}
+	if p := <-pong; string(p) != "p" {
+		t.Errorf("expected the pong to echo the ping, got %q", p)
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	c, client := pipe(t)
+
+	long := strings.Repeat("x", 300)
+	go c.WriteMessage(OpText, []byte(long))
+
+	op, payload, err := readServerFrame(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != OpText || string(payload) != long {
+		t.Errorf("expected the 300 byte message back, got opcode %d and %d bytes", op, len(payload))
+	}
+}
+
+func TestCloseHandshake(t *testing.T) {
+	c, client := pipe(t)
+
+	go client.Write(clientFrame(true, OpClose, append(binary.BigEndian.AppendUint16(nil, CloseGoingAway), "bye"...)))
+
+	echoed := make(chan int, 1)
+	go func() {
+		op, payload, err := readServerFrame(client)
+		if err != nil || op != OpClose || len(payload) < 2 {
+			t.Errorf("expected a close frame, got opcode %d: %v", op, err)
+			echoed <- 0
+			return
+		}
+		echoed <- int(binary.BigEndian.Uint16(payload))
+	}()
+
+	_, _, err := c.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Reason != "bye" {
+		t.Fatalf("expected a going away CloseError, got %v", err)
+	}
+	if code := <-echoed; code != CloseGoingAway {
+		t.Errorf("expected the close code to be echoed, got %d", code)
+	}
+
+	if err := c.WriteMessage(OpText, []byte("late")); err == nil {
+		t.Errorf("expected writes after close to fail")
+	}
+}
+
+func TestReadMessageTooBig(t *testing.T) {
+	c, client := pipe(t)
+	c.MaxMessageSize = 4
+
+	go client.Write(clientFrame(true, OpText, []byte("too long")))
+	go readServerFrame(client)
+
+	if _, _, err := c.ReadMessage(); err != ErrMessageTooBig {
+		t.Errorf("expected ErrMessageTooBig, got %v", err)
+	}
+}
+
+func TestReadMessageRejectsUnmasked(t *testing.T) {
+	c, client := pipe(t)
+
+	go client.Write([]byte{0x81, 0x02, 'h', 'i'})
+	go readServerFrame(client)
+
+	if _, _, err := c.ReadMessage(); err == nil {
+		t.Errorf("expected an unmasked client frame to be rejected")
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	upgraded := make(chan *Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err != nil {
+			upgraded <- nil
+			return
+		}
+		upgraded <- c
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := "GET / HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept header %q", got)
+	}
+
+	c := <-upgraded
+	if c == nil {
+		t.Fatal("expected the upgrade to succeed")
+	}
+	defer c.Close()
+
+	conn.Write(clientFrame(true, OpText, []byte("hi")))
+	if _, data, err := c.ReadMessage(); err != nil || string(data) != "hi" {
+		t.Errorf("expected hi, got %q %v", data, err)
+	}
+}
+
+func TestUpgradeRejectsPlainRequest(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	if _, err := Upgrade(w, r); err == nil {
+		t.Fatal("expected a plain request to be rejected")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxListsPerUser        = 50
+	maxListMembers         = 500
+	maxListNameLength      = 50
+	maxListDescriptionSize = 200
+)
+
+type listInfo struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UserID      uuid.UUID `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Private     bool      `json:"private"`
+}
+
+func newListInfo(l database.List) listInfo {
+	return listInfo{
+		ID:          l.ID,
+		CreatedAt:   l.CreatedAt,
+		UpdatedAt:   l.UpdatedAt,
+		UserID:      l.UserID,
+		Name:        l.Name,
+		Description: l.Description,
+		Private:     l.IsPrivate,
+	}
+}
+
+func validateList(name, description string) error {
+	if name == "" || len(name) > maxListNameLength {
+		return fmt.Errorf("name must be between 1 and %d characters", maxListNameLength)
+	}
+	if len(description) > maxListDescriptionSize {
+		return fmt.Errorf("description is limited to %d characters", maxListDescriptionSize)
+	}
+	return nil
+}
+
+func writeList(w http.ResponseWriter, list database.List, code int) {
+	data, err := json.Marshal(newListInfo(list))
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+func writeLists(w http.ResponseWriter, lists []database.List) {
+	responseData := []listInfo{}
+	for _, l := range lists {
+		responseData = append(responseData, newListInfo(l))
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+// ownList authenticates the request and loads the list in the path, which
+// must belong to the caller.
+func (cfg *apiConfig) ownList(w http.ResponseWriter, r *http.Request) (database.List, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return database.List{}, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return database.List{}, false
+	}
+
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return database.List{}, false
+	}
+
+	list, err := cfg.db.GetList(r.Context(), listID)
+	if err != nil || list.UserID != userID {
+		returnError(w, fmt.Errorf("list not found"), 404)
+		return database.List{}, false
+	}
+	return list, true
+}
+
+// visibleList loads the list in the path for any viewer allowed to see it:
+// its owner, or anyone not blocked when it's public.
+func (cfg *apiConfig) visibleList(w http.ResponseWriter, r *http.Request) (database.List, uuid.NullUUID, bool) {
+	viewer, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		returnError(w, err, 401)
+		return database.List{}, uuid.NullUUID{}, false
+	}
+
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return database.List{}, uuid.NullUUID{}, false
+	}
+
+	list, err := cfg.db.GetList(r.Context(), listID)
+	if err != nil {
+		returnError(w, fmt.Errorf("list not found"), 404)
+		return database.List{}, uuid.NullUUID{}, false
+	}
+
+	if viewer.Valid && viewer.UUID == list.UserID {
+		return list, viewer, true
+	}
+	if list.IsPrivate {
+		returnError(w, fmt.Errorf("list not found"), 404)
+		return database.List{}, uuid.NullUUID{}, false
+	}
+	if viewer.Valid {
+		blocked, err := cfg.blockedBetween(r.Context(), viewer.UUID, list.UserID)
+		if err != nil {
+			returnError(w, err, 500)
+			return database.List{}, uuid.NullUUID{}, false
+		}
+		if blocked {
+			returnError(w, fmt.Errorf("list not found"), 404)
+			return database.List{}, uuid.NullUUID{}, false
+		}
+	}
+	return list, viewer, true
+}
+
+func (cfg *apiConfig) handlerPostLists(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	type requestVals struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Private     bool   `json:"private"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	name := strings.TrimSpace(params.Name)
+	if err := validateList(name, params.Description); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	count, err := cfg.db.CountListsForUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if count >= maxListsPerUser {
+		returnError(w, fmt.Errorf("you can have at most %d lists", maxListsPerUser), 400)
+		return
+	}
+
+	createListArgs := database.CreateListParams{
+		UserID:      userID,
+		Name:        name,
+		Description: params.Description,
+		IsPrivate:   params.Private,
+	}
+	list, err := cfg.db.CreateList(r.Context(), createListArgs)
+	if errors.Is(err, sql.ErrNoRows) {
+		returnError(w, fmt.Errorf("you already have a list named %q", name), 409)
+		return
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	writeList(w, list, 201)
+}
+
+func (cfg *apiConfig) handlerGetLists(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	lists, err := cfg.db.GetListsForUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	writeLists(w, lists)
+}
+
+func (cfg *apiConfig) handlerGetSubscribedLists(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	lists, err := cfg.db.GetSubscribedLists(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	writeLists(w, lists)
+}
+
+func (cfg *apiConfig) handlerGetList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	list, _, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+
+	writeList(w, list, 200)
+}
+
+func (cfg *apiConfig) handlerPatchList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	list, ok := cfg.ownList(w, r)
+	if !ok {
+		return
+	}
+
+	type requestVals struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Private     *bool   `json:"private"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	updateListArgs := database.UpdateListParams{
+		ID:          list.ID,
+		Name:        list.Name,
+		Description: list.Description,
+		IsPrivate:   list.IsPrivate,
+	}
+	if params.Name != nil {
+		updateListArgs.Name = strings.TrimSpace(*params.Name)
+	}
+	if params.Description != nil {
+		updateListArgs.Description = *params.Description
+	}
+	if params.Private != nil {
+		updateListArgs.IsPrivate = *params.Private
+	}
+	if err := validateList(updateListArgs.Name, updateListArgs.Description); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	updated, err := cfg.db.UpdateList(r.Context(), updateListArgs)
+	if errors.Is(err, sql.ErrNoRows) {
+		returnError(w, fmt.Errorf("you already have a list named %q", updateListArgs.Name), 409)
+		return
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	writeList(w, updated, 200)
+}
+
+func (cfg *apiConfig) handlerDeleteList(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.ownList(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cfg.db.DeleteList(r.Context(), list.ID); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetListMembers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	list, _, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	getListMembersArgs := database.GetListMembersParams{
+		ListID:     list.ID,
+		BeforeTime: cursor.Time,
+		BeforeID:   cursor.ID,
+		PageSize:   int32(limit + 1),
+	}
+	members, err := cfg.db.GetListMembers(r.Context(), getListMembersArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	items := make([]relationshipInfo, 0, len(members))
+	for _, m := range members {
+		items = append(items, relationshipInfo{UserID: m.UserID, CreatedAt: m.CreatedAt})
+	}
+	writePage(w, newPage(items, limit, func(m relationshipInfo) pageCursor {
+		return pageCursor{Time: m.CreatedAt, ID: m.UserID}
+	}))
+}
+
+func (cfg *apiConfig) handlerPostListMembers(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.ownList(w, r)
+	if !ok {
+		return
+	}
+
+	type requestVals struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	if _, err := cfg.db.GetUser(r.Context(), params.UserID); err != nil {
+		returnError(w, fmt.Errorf("user not found"), 404)
+		return
+	}
+	// Lists can't be used to keep watching someone who blocked you.
+	blocked, err := cfg.blockedBetween(r.Context(), list.UserID, params.UserID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if blocked {
+		returnError(w, fmt.Errorf("user not found"), 404)
+		return
+	}
+
+	count, err := cfg.db.CountListMembers(r.Context(), list.ID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if count >= maxListMembers {
+		returnError(w, fmt.Errorf("lists can have at most %d members", maxListMembers), 400)
+		return
+	}
+
+	addListMemberArgs := database.AddListMemberParams{
+		ListID: list.ID,
+		UserID: params.UserID,
+	}
+	if _, err := cfg.db.AddListMember(r.Context(), addListMemberArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerDeleteListMember(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.ownList(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	removeListMemberArgs := database.RemoveListMemberParams{
+		ListID: list.ID,
+		UserID: userID,
+	}
+	n, err := cfg.db.RemoveListMember(r.Context(), removeListMemberArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if n == 0 {
+		returnError(w, fmt.Errorf("user is not on this list"), 404)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerPostListSubscription(w http.ResponseWriter, r *http.Request) {
+	list, viewer, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+	if !viewer.Valid {
+		returnError(w, fmt.Errorf("sign in to subscribe to lists"), 401)
+		return
+	}
+	if viewer.UUID == list.UserID {
+		returnError(w, fmt.Errorf("cannot subscribe to your own list"), 400)
+		return
+	}
+
+	createListSubscriptionArgs := database.CreateListSubscriptionParams{
+		ListID: list.ID,
+		UserID: viewer.UUID,
+	}
+	if err := cfg.db.CreateListSubscription(r.Context(), createListSubscriptionArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerDeleteListSubscription(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	// Unsubscribing works even after the list went private or its owner
+	// blocked you.
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	deleteListSubscriptionArgs := database.DeleteListSubscriptionParams{
+		ListID: listID,
+		UserID: userID,
+	}
+	if _, err := cfg.db.DeleteListSubscription(r.Context(), deleteListSubscriptionArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetListTimeline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	list, viewer, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	// Blocks and mutes are the viewer's, not the list owner's.
+	getListTimelineArgs := database.GetListTimelineParams{
+		ListID:     list.ID,
+		ViewerID:   viewer.UUID,
+		BeforeTime: cursor.Time,
+		BeforeID:   cursor.ID,
+		PageSize:   int32(limit + 1),
+	}
+	chirps, err := cfg.db.GetListTimeline(r.Context(), getListTimelineArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	infos, err := chirpInfos(r.Context(), cfg.db, viewer, chirps)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	writePage(w, newPage(infos, limit, func(c chirpInfo) pageCursor {
+		return pageCursor{Time: c.CreatedAt, ID: c.ID}
+	}))
+}
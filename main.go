@@ -1,12 +1,27 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/blobstore"
 	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/entitlements"
+	"github.com/MagnusTrier/chirpy/internal/moderation"
+	"github.com/MagnusTrier/chirpy/internal/oidc"
+	"github.com/MagnusTrier/chirpy/internal/pubsub"
+	"github.com/MagnusTrier/chirpy/internal/ratelimit"
+	"github.com/MagnusTrier/chirpy/internal/webhooks"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -17,12 +32,61 @@ func handlerHealthz(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+func envInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		fmt.Printf("invalid %s %q, using %d\n", key, v, fallback)
+		return fallback
+	}
+	return n
+}
+
 func main() {
+	calibrate := flag.Duration("calibrate-argon2", 0, "print argon2id params that take roughly this long per hash, then exit")
+	flag.Parse()
+
 	godotenv.Load()
+
+	hashParams, err := auth.NewHashParams(
+		envInt("ARGON2_MEMORY_KIB", int(auth.DefaultHashParams.Memory)),
+		envInt("ARGON2_ITERATIONS", int(auth.DefaultHashParams.Iterations)),
+		envInt("ARGON2_PARALLELISM", int(auth.DefaultHashParams.Parallelism)),
+	)
+	if err != nil {
+		fmt.Print(err)
+		return
+	}
+
+	if *calibrate > 0 {
+		params, took, err := auth.CalibrateHashParams(*calibrate, hashParams.Memory, hashParams.Parallelism)
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+		fmt.Printf("ARGON2_MEMORY_KIB=%d\nARGON2_ITERATIONS=%d\nARGON2_PARALLELISM=%d\n# %v per hash\n",
+			params.Memory, params.Iterations, params.Parallelism, took.Round(time.Millisecond))
+		return
+	}
+
 	dbURL := os.Getenv("DB_URL")
 	platform := os.Getenv("PLATFORM")
 	jwtSecret := os.Getenv("JWT_SECRET")
-	polkaKey := os.Getenv("POLKA_KEY")
+	adminKey := os.Getenv("ADMIN_API_KEY")
+
+	// POLKA_WEBHOOK_SECRETS takes a comma separated list so a new secret can
+	// be added before the old one is retired.
+	polkaSecrets := strings.Split(os.Getenv("POLKA_WEBHOOK_SECRETS"), ",")
+	if os.Getenv("POLKA_WEBHOOK_SECRETS") == "" {
+		polkaSecrets = []string{os.Getenv("POLKA_KEY")}
+	}
+	for i := range polkaSecrets {
+		polkaSecrets[i] = strings.TrimSpace(polkaSecrets[i])
+	}
+	polkaTolerance := time.Duration(envInt("POLKA_SIGNATURE_TOLERANCE_SECONDS", 300)) * time.Second
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
@@ -32,15 +96,139 @@ func main() {
 
 	dbQueries := database.New(db)
 
+	passwordPolicy := auth.DefaultPasswordPolicy
+	passwordPolicy.MinLength = envInt("PASSWORD_MIN_LENGTH", passwordPolicy.MinLength)
+	passwordPolicy.MaxLength = envInt("PASSWORD_MAX_LENGTH", passwordPolicy.MaxLength)
+	passwordPolicy.DisallowEmail = os.Getenv("PASSWORD_ALLOW_EMAIL") != "true"
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		breached, err := auth.LoadBreachedPasswords(path)
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+		passwordPolicy.Breached = breached
+	}
+
+	oidcProviders := map[string]*oidc.Provider{}
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		name := os.Getenv("OIDC_PROVIDER_NAME")
+		if name == "" {
+			name = "oidc"
+		}
+		oidcConfig := oidc.Config{
+			Name:         name,
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		}
+		if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+			oidcConfig.Scopes = strings.Fields(scopes)
+		}
+		oidcProviders[name] = oidc.NewProvider(oidcConfig)
+	}
+
+	planConfig := entitlements.Default
+	if path := os.Getenv("ENTITLEMENTS_FILE"); path != "" {
+		planConfig, err = entitlements.Load(path)
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+	}
+
+	moderator, err := moderation.NewModerator(os.Getenv("MODERATION_RULES_FILE"))
+	if err != nil {
+		fmt.Print(err)
+		return
+	}
+
+	var blobStore blobstore.BlobStore
+	switch os.Getenv("MEDIA_STORE") {
+	case "s3":
+		blobStore, err = blobstore.NewS3Store(blobstore.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          os.Getenv("S3_REGION"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		})
+	case "", "fs":
+		dir := os.Getenv("MEDIA_DIR")
+		if dir == "" {
+			dir = "media"
+		}
+		blobStore, err = blobstore.NewFileStore(dir)
+	default:
+		err = fmt.Errorf("unknown MEDIA_STORE %q", os.Getenv("MEDIA_STORE"))
+	}
+	if err != nil {
+		fmt.Print(err)
+		return
+	}
+
+	streams, stopStreams := context.WithCancel(context.Background())
+
+	// With more than one instance, events have to go through Postgres to
+	// reach clients connected to the others.
+	localBroker := pubsub.NewMemoryBroker(envInt("STREAM_HISTORY_SIZE", 1000), envInt("STREAM_BUFFER_SIZE", 64))
+	var broker pubsub.Broker
+	switch os.Getenv("STREAM_BROKER") {
+	case "postgres":
+		postgresBroker := pubsub.NewPostgresBroker(db, dbURL, "chirpy_events", localBroker)
+		go postgresBroker.Run(streams, func(err error) {
+			fmt.Printf("stream broker: %v\n", err)
+		})
+		broker = postgresBroker
+	case "", "memory":
+		broker = localBroker
+	default:
+		fmt.Printf("unknown STREAM_BROKER %q", os.Getenv("STREAM_BROKER"))
+		return
+	}
+
 	mux := http.NewServeMux()
 
 	apiCfg := apiConfig{
 		db:        dbQueries,
+		dbConn:    db,
 		platform:  platform,
 		jwtSecret: jwtSecret,
-		polkaKey:  polkaKey,
+
+		polkaSecrets:   polkaSecrets,
+		polkaTolerance: polkaTolerance,
+		adminKey:       adminKey,
+		subGrace:       time.Duration(envInt("SUBSCRIPTION_GRACE_HOURS", 72)) * time.Hour,
+		trashRetention: time.Duration(envInt("CHIRP_TRASH_RETENTION_HOURS", 24*30)) * time.Hour,
+		editWindow:     time.Duration(envInt("CHIRP_EDIT_WINDOW_MINUTES", 60)) * time.Minute,
+
+		passwordPolicy: passwordPolicy,
+		hashParams:     hashParams,
+		oidcProviders:  oidcProviders,
+		entitlements:   planConfig,
+		chirpLimiter:   ratelimit.New(time.Hour),
+		webhookSender:  webhooks.NewSender(os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"),
+		moderator:      moderator,
+		blobStore:      blobStore,
+		mediaMaxBytes:  int64(envInt("MEDIA_MAX_BYTES", 5<<20)),
+		mediaMaxPixels: int64(envInt("MEDIA_MAX_PIXELS", 40_000_000)),
+		mediaWake:      make(chan struct{}, 1),
+
+		broker:          broker,
+		streamHeartbeat: time.Duration(envInt("STREAM_HEARTBEAT_SECONDS", 15)) * time.Second,
+		streams:         streams,
 	}
 
+	go apiCfg.runSubscriptionScheduler(context.Background(), time.Duration(envInt("SUBSCRIPTION_SWEEP_SECONDS", 60))*time.Second)
+	go apiCfg.runChirpPublisher(context.Background(), time.Duration(envInt("SCHEDULED_CHIRPS_POLL_SECONDS", 10))*time.Second)
+	go apiCfg.runTrashPurger(context.Background(), time.Duration(envInt("CHIRP_TRASH_PURGE_SECONDS", 3600))*time.Second)
+	go apiCfg.runUploadSweeper(context.Background(), time.Duration(envInt("MEDIA_SWEEP_SECONDS", 3600))*time.Second, time.Duration(envInt("MEDIA_UNATTACHED_HOURS", 24))*time.Hour)
+	go apiCfg.runMediaWorkers(context.Background(), envInt("MEDIA_WORKERS", 2), time.Duration(envInt("MEDIA_WORKER_POLL_SECONDS", 10))*time.Second)
+	go apiCfg.runWebhookWorker(context.Background(), time.Duration(envInt("WEBHOOK_WORKER_SECONDS", 5))*time.Second)
+	go moderator.Watch(context.Background(), time.Duration(envInt("MODERATION_RELOAD_SECONDS", 10))*time.Second, func(err error) {
+		fmt.Printf("moderation rules: %v\n", err)
+	})
+
 	filepath := http.Dir(".")
 
 	handler := http.StripPrefix("/app/", http.FileServer(filepath))
@@ -49,6 +237,16 @@ func main() {
 
 	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerFileserverHits)
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerResetUsers)
+	mux.HandleFunc("GET /admin/webhook-events", apiCfg.handlerGetWebhookEvents)
+	mux.HandleFunc("POST /admin/webhook-events/{eventID}/replay", apiCfg.handlerPostWebhookEventReplay)
+
+	mux.HandleFunc("GET /admin/moderation-flags", apiCfg.handlerGetModerationFlags)
+	mux.HandleFunc("GET /admin/moderation-actions", apiCfg.handlerGetModerationActions)
+	mux.HandleFunc("GET /admin/reports", apiCfg.handlerGetReports)
+	mux.HandleFunc("GET /admin/reports/{reportID}", apiCfg.handlerGetReport)
+	mux.HandleFunc("POST /admin/reports/{reportID}/assign", apiCfg.handlerPostReportAssign)
+	mux.HandleFunc("POST /admin/reports/{reportID}/status", apiCfg.handlerPostReportStatus)
+	mux.HandleFunc("POST /admin/reports/{reportID}/resolve", apiCfg.handlerPostReportResolve)
 
 	mux.HandleFunc("GET /api/healthz", handlerHealthz)
 
@@ -59,17 +257,114 @@ func main() {
 	mux.HandleFunc("POST /api/users", apiCfg.handlerPostUsers)
 
 	mux.HandleFunc("POST /api/login", apiCfg.handlerPostLogin)
+	mux.HandleFunc("GET /api/auth/{provider}/login", apiCfg.handlerGetOidcLogin)
+	mux.HandleFunc("GET /api/auth/{provider}/callback", apiCfg.handlerGetOidcCallback)
 
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerPostRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerPostRevoke)
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerPutUsers)
+	mux.HandleFunc("GET /api/subscription", apiCfg.handlerGetSubscription)
+	mux.HandleFunc("GET /api/entitlements", apiCfg.handlerGetEntitlements)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
+	mux.HandleFunc("PATCH /api/chirps/{chirpID}", apiCfg.handlerPatchChirp)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.handlerGetChirpRevisions)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", apiCfg.handlerPostChirpReport)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.handlerPostChirpRestore)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", apiCfg.handlerPostPollVote)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.handlerPostBookmark)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.handlerDeleteBookmark)
+	mux.HandleFunc("GET /api/users/me/bookmarks", apiCfg.handlerGetBookmarks)
+	mux.HandleFunc("POST /api/collections", apiCfg.handlerPostCollections)
+	mux.HandleFunc("GET /api/collections", apiCfg.handlerGetCollections)
+	mux.HandleFunc("GET /api/collections/{collectionID}", apiCfg.handlerGetCollection)
+	mux.HandleFunc("PATCH /api/collections/{collectionID}", apiCfg.handlerPatchCollection)
+	mux.HandleFunc("DELETE /api/collections/{collectionID}", apiCfg.handlerDeleteCollection)
+	mux.HandleFunc("GET /api/collections/{collectionID}/chirps", apiCfg.handlerGetCollectionChirps)
+	mux.HandleFunc("POST /api/collections/{collectionID}/chirps", apiCfg.handlerPostCollectionChirp)
+	mux.HandleFunc("DELETE /api/collections/{collectionID}/chirps/{chirpID}", apiCfg.handlerDeleteCollectionChirp)
+	mux.HandleFunc("POST /api/lists", apiCfg.handlerPostLists)
+	mux.HandleFunc("GET /api/lists", apiCfg.handlerGetLists)
+	mux.HandleFunc("GET /api/lists/subscribed", apiCfg.handlerGetSubscribedLists)
+	mux.HandleFunc("GET /api/lists/{listID}", apiCfg.handlerGetList)
+	mux.HandleFunc("PATCH /api/lists/{listID}", apiCfg.handlerPatchList)
+	mux.HandleFunc("DELETE /api/lists/{listID}", apiCfg.handlerDeleteList)
+	mux.HandleFunc("GET /api/lists/{listID}/members", apiCfg.handlerGetListMembers)
+	mux.HandleFunc("POST /api/lists/{listID}/members", apiCfg.handlerPostListMembers)
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", apiCfg.handlerDeleteListMember)
+	mux.HandleFunc("POST /api/lists/{listID}/subscription", apiCfg.handlerPostListSubscription)
+	mux.HandleFunc("DELETE /api/lists/{listID}/subscription", apiCfg.handlerDeleteListSubscription)
+	mux.HandleFunc("GET /api/lists/{listID}/timeline", apiCfg.handlerGetListTimeline)
+	mux.HandleFunc("GET /api/users/me/trash", apiCfg.handlerGetTrash)
+	mux.HandleFunc("GET /api/users/me/warnings", apiCfg.handlerGetWarnings)
+	mux.HandleFunc("POST /api/media", apiCfg.handlerPostMedia)
+	mux.HandleFunc("GET /api/media/{attachmentID}", apiCfg.handlerGetMedia)
+	mux.HandleFunc("GET /api/media/{attachmentID}/{variant}", apiCfg.handlerGetMediaVariant)
+	mux.HandleFunc("GET /api/media/{attachmentID}/{variant}/{key}", apiCfg.handlerGetMediaVariant)
+	mux.HandleFunc("GET /api/users/me/scheduled", apiCfg.handlerGetScheduledChirps)
+	mux.HandleFunc("PATCH /api/users/me/scheduled/{scheduledID}", apiCfg.handlerPatchScheduledChirp)
+	mux.HandleFunc("DELETE /api/users/me/scheduled/{scheduledID}", apiCfg.handlerDeleteScheduledChirp)
+	mux.HandleFunc("POST /api/blocks", apiCfg.handlerPostBlocks)
+	mux.HandleFunc("GET /api/blocks", apiCfg.handlerGetBlocks)
+	mux.HandleFunc("DELETE /api/blocks/{userID}", apiCfg.handlerDeleteBlock)
+	mux.HandleFunc("POST /api/mutes", apiCfg.handlerPostMutes)
+	mux.HandleFunc("GET /api/mutes", apiCfg.handlerGetMutes)
+	mux.HandleFunc("DELETE /api/mutes/{userID}", apiCfg.handlerDeleteMute)
+	mux.HandleFunc("POST /api/follows", apiCfg.handlerPostFollows)
+	mux.HandleFunc("GET /api/follows", apiCfg.handlerGetFollows)
+	mux.HandleFunc("DELETE /api/follows/{userID}", apiCfg.handlerDeleteFollow)
+	mux.HandleFunc("GET /api/followers", apiCfg.handlerGetFollowers)
+	mux.HandleFunc("GET /api/users/me/dm-settings", apiCfg.handlerGetDMSettings)
+	mux.HandleFunc("PUT /api/users/me/dm-settings", apiCfg.handlerPutDMSettings)
+	mux.HandleFunc("POST /api/conversations", apiCfg.handlerPostConversations)
+	mux.HandleFunc("GET /api/conversations", apiCfg.handlerGetConversations)
+	mux.HandleFunc("GET /api/conversations/{conversationID}", apiCfg.handlerGetConversation)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", apiCfg.handlerGetDirectMessages)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", apiCfg.handlerPostDirectMessage)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", apiCfg.handlerPostConversationRead)
+	mux.HandleFunc("GET /api/notifications", apiCfg.handlerGetNotifications)
+	mux.HandleFunc("GET /api/stream", apiCfg.handlerGetStream)
+	mux.HandleFunc("GET /api/ws", apiCfg.handlerWebSocket)
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.handlerPostNotificationsRead)
+	mux.HandleFunc("POST /api/notifications/{notificationID}/read", apiCfg.handlerPostNotificationRead)
+	mux.HandleFunc("GET /api/users/me/notification-settings", apiCfg.handlerGetNotificationSettings)
+	mux.HandleFunc("PUT /api/users/me/notification-settings", apiCfg.handlerPutNotificationSettings)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPostPolkaWebhooks)
 
+	mux.HandleFunc("POST /api/webhooks", apiCfg.handlerPostWebhookEndpoints)
+	mux.HandleFunc("GET /api/webhooks", apiCfg.handlerGetWebhookEndpoints)
+	mux.HandleFunc("DELETE /api/webhooks/{endpointID}", apiCfg.handlerDeleteWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", apiCfg.handlerGetWebhookDeliveries)
+
+	mux.HandleFunc("POST /api/oauth/clients", apiCfg.handlerPostOauthClients)
+	mux.HandleFunc("GET /api/oauth/clients", apiCfg.handlerGetOauthClients)
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.handlerDeleteOauthClient)
+	mux.HandleFunc("GET /oauth/authorize", apiCfg.handlerGetOauthAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", apiCfg.handlerPostOauthAuthorize)
+	mux.HandleFunc("POST /oauth/token", apiCfg.handlerPostOauthToken)
+	mux.HandleFunc("POST /oauth/revoke", apiCfg.handlerPostOauthRevoke)
+	mux.HandleFunc("POST /oauth/introspect", apiCfg.handlerPostOauthIntrospect)
+
 	s := http.Server{
 		Handler: mux,
 		Addr:    ":8080",
 	}
+	s.RegisterOnShutdown(stopStreams)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
-	s.ListenAndServe()
+	go func() {
+		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Print(err)
+			os.Exit(1)
+		}
+	}()
+
+	<-stop
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		fmt.Print(err)
+	}
+	apiCfg.waitForStreams(shutdownCtx)
 }
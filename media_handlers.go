@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/blobstore"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/imaging"
+	"github.com/google/uuid"
+)
+
+const (
+	maxAttachmentsPerChirp = 4
+	maxAltTextLength       = 1000
+	mediaMaxAge            = 5 * time.Minute
+	mediaVariantMaxAge     = 365 * 24 * time.Hour
+)
+
+// Uploads are sniffed rather than trusting the client's Content-Type, and only
+// formats the standard library can decode are accepted.
+var mediaExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+type attachmentInfo struct {
+	ID          uuid.UUID `json:"id"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	Width       int32     `json:"width"`
+	Height      int32     `json:"height"`
+	AltText     string    `json:"alt_text"`
+	// Blurhash and Variants appear once the upload has been processed.
+	Blurhash *string       `json:"blurhash"`
+	Variants []variantInfo `json:"variants"`
+}
+
+type variantInfo struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Width  int32  `json:"width"`
+	Height int32  `json:"height"`
+}
+
+func newAttachmentInfo(a database.Attachment) attachmentInfo {
+	info := attachmentInfo{
+		ID:          a.ID,
+		URL:         "/api/media/" + a.ID.String(),
+		ContentType: a.ContentType,
+		SizeBytes:   a.SizeBytes,
+		Width:       a.Width,
+		Height:      a.Height,
+		AltText:     a.AltText,
+		Variants:    []variantInfo{},
+	}
+	if a.Blurhash.Valid {
+		info.Blurhash = &a.Blurhash.String
+	}
+	return info
+}
+
+func newVariantInfo(v database.AttachmentVariant) variantInfo {
+	return variantInfo{
+		Name:   v.Name,
+		URL:    variantURL(v),
+		Width:  v.Width,
+		Height: v.Height,
+	}
+}
+
+// variantURL includes the variant's url_key, which changes whenever its chirp
+// is hidden or deleted. That lets the variant be cached for a long time: a
+// copy cached under an old URL can't be found by anyone who didn't already
+// have it, and the origin stops serving that URL.
+func variantURL(v database.AttachmentVariant) string {
+	return fmt.Sprintf("/api/media/%s/%s/%s", v.AttachmentID, v.Name, v.UrlKey)
+}
+
+// attachmentInfosForChirps loads the attachments and their variants for a set
+// of chirps in two queries.
+func attachmentInfosForChirps(ctx context.Context, q *database.Queries, chirpIDs []uuid.UUID) (map[uuid.UUID][]attachmentInfo, error) {
+	attachments, err := q.GetAttachmentsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	attachmentIDs := make([]uuid.UUID, 0, len(attachments))
+	for _, a := range attachments {
+		attachmentIDs = append(attachmentIDs, a.ID)
+	}
+
+	variants, err := q.GetVariantsForAttachments(ctx, attachmentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byAttachment := map[uuid.UUID][]variantInfo{}
+	for _, v := range variants {
+		byAttachment[v.AttachmentID] = append(byAttachment[v.AttachmentID], newVariantInfo(v))
+	}
+
+	byChirp := map[uuid.UUID][]attachmentInfo{}
+	for _, a := range attachments {
+		info := newAttachmentInfo(a)
+		if v, ok := byAttachment[a.ID]; ok {
+			info.Variants = v
+		}
+		byChirp[a.ChirpID.UUID] = append(byChirp[a.ChirpID.UUID], info)
+	}
+	return byChirp, nil
+}
+
+func (cfg *apiConfig) handlerPostMedia(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeChirpsWrite)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	// Leave room for the multipart framing and the alt text.
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.mediaMaxBytes+64<<10)
+	if err := r.ParseMultipartForm(cfg.mediaMaxBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			returnError(w, fmt.Errorf("uploads are limited to %d bytes", cfg.mediaMaxBytes), 413)
+			return
+		}
+		returnError(w, err, 400)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		returnError(w, err, 400)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, cfg.mediaMaxBytes+1))
+	if err != nil {
+		returnError(w, err, 400)
+		return
+	}
+	if int64(len(data)) > cfg.mediaMaxBytes {
+		returnError(w, fmt.Errorf("uploads are limited to %d bytes", cfg.mediaMaxBytes), 413)
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := mediaExtensions[contentType]
+	if !ok {
+		returnError(w, fmt.Errorf("unsupported media type %s", contentType), 415)
+		return
+	}
+
+	// Re-encoding from the decoded pixels drops EXIF (including GPS) and any
+	// other metadata before anything is stored.
+	decoded, err := imaging.Decode(data, cfg.mediaMaxPixels)
+	if errors.Is(err, imaging.ErrTooManyPixels) {
+		returnError(w, fmt.Errorf("images are limited to %d pixels", cfg.mediaMaxPixels), 413)
+		return
+	}
+	if err != nil {
+		returnError(w, fmt.Errorf("could not read image: %w", err), 400)
+		return
+	}
+
+	clean, err := decoded.Encode()
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	bounds := decoded.Image.Bounds()
+
+	altText := r.FormValue("alt_text")
+	if len(altText) > maxAltTextLength {
+		returnError(w, fmt.Errorf("alt_text is limited to %d characters", maxAltTextLength), 400)
+		return
+	}
+
+	attachmentID := uuid.New()
+	key := fmt.Sprintf("attachments/%s%s", attachmentID, ext)
+	if err := cfg.blobStore.Put(r.Context(), key, clean, contentType); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	createAttachmentArgs := database.CreateAttachmentParams{
+		ID:          attachmentID,
+		UserID:      userID,
+		StorageKey:  key,
+		ContentType: contentType,
+		SizeBytes:   int64(len(clean)),
+		Width:       int32(bounds.Dx()),
+		Height:      int32(bounds.Dy()),
+		AltText:     altText,
+	}
+	attachment, err := cfg.db.CreateAttachment(r.Context(), createAttachmentArgs)
+	if err != nil {
+		if err := cfg.blobStore.Delete(r.Context(), key); err != nil {
+			fmt.Print(err)
+		}
+		returnError(w, err, 500)
+		return
+	}
+
+	cfg.wakeMediaWorkers()
+
+	body, err := json.Marshal(newAttachmentInfo(attachment))
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(201)
+	w.Write(body)
+}
+
+// visibleAttachment loads the attachment named in the path, hiding media that
+// belongs to a hidden or deleted chirp.
+func (cfg *apiConfig) visibleAttachment(w http.ResponseWriter, r *http.Request) (database.Attachment, bool) {
+	attachmentID, err := uuid.Parse(r.PathValue("attachmentID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return database.Attachment{}, false
+	}
+
+	attachment, err := cfg.db.GetAttachment(r.Context(), attachmentID)
+	if err != nil {
+		returnError(w, fmt.Errorf("media not found"), 404)
+		return database.Attachment{}, false
+	}
+
+	if attachment.ChirpID.Valid {
+		chirp, err := cfg.db.GetChirp(r.Context(), attachment.ChirpID.UUID)
+		if err != nil || chirp.HiddenAt.Valid {
+			returnError(w, fmt.Errorf("media not found"), 404)
+			return database.Attachment{}, false
+		}
+	}
+
+	return attachment, true
+}
+
+// serveBlob sends media with the given Cache-Control header.
+func (cfg *apiConfig) serveBlob(w http.ResponseWriter, r *http.Request, key, contentType string, modified time.Time, cacheControl string) {
+	rc, err := cfg.blobStore.Get(r.Context(), key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		returnError(w, fmt.Errorf("media not found"), 404)
+		return
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", cacheControl)
+	w.WriteHeader(200)
+	io.Copy(w, rc)
+}
+
+func (cfg *apiConfig) handlerGetMedia(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := cfg.visibleAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	// Originals live at a fixed URL and stop being served once their chirp
+	// is hidden or deleted, so caches mustn't keep them long.
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(mediaMaxAge.Seconds()))
+	cfg.serveBlob(w, r, attachment.StorageKey, attachment.ContentType, attachment.CreatedAt, cacheControl)
+}
+
+func (cfg *apiConfig) handlerGetMediaVariant(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := cfg.visibleAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	getAttachmentVariantArgs := database.GetAttachmentVariantParams{
+		AttachmentID: attachment.ID,
+		Name:         r.PathValue("variant"),
+	}
+	variant, err := cfg.db.GetAttachmentVariant(r.Context(), getAttachmentVariantArgs)
+	if err != nil {
+		returnError(w, fmt.Errorf("media variant not found"), 404)
+		return
+	}
+
+	// URLs handed out before variants had keys point to the current one.
+	key := r.PathValue("key")
+	if key == "" {
+		w.Header().Set("Cache-Control", "no-cache")
+		http.Redirect(w, r, variantURL(variant), http.StatusFound)
+		return
+	}
+	if key != variant.UrlKey.String() {
+		returnError(w, fmt.Errorf("media variant not found"), 404)
+		return
+	}
+
+	cacheControl := fmt.Sprintf("public, max-age=%d, immutable", int(mediaVariantMaxAge.Seconds()))
+	cfg.serveBlob(w, r, variant.StorageKey, variant.ContentType, attachment.CreatedAt, cacheControl)
+}
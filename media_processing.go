@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/blobstore"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/imaging"
+	"github.com/google/uuid"
+)
+
+// Variants are only generated when the original is larger than their bound;
+// clients fall back to the original otherwise.
+var mediaVariants = []struct {
+	name   string
+	maxDim int
+}{
+	{"thumb", 320},
+	{"small", 640},
+	{"large", 1280},
+}
+
+// A transient processing error is retried mediaRetryDelay later, up to
+// mediaMaxProcessingAttempts times.
+const (
+	mediaMaxProcessingAttempts = 5
+	mediaRetryDelay            = 5 * time.Minute
+)
+
+// errUnprocessableMedia marks processing errors that retrying won't fix.
+var errUnprocessableMedia = errors.New("unprocessable media")
+
+func mediaVariantKey(attachmentID uuid.UUID, name, contentType string) string {
+	return fmt.Sprintf("attachments/%s/%s%s", attachmentID, name, mediaExtensions[contentType])
+}
+
+// processNextAttachment generates the variants and blurhash for one uploaded
+// image. The row stays locked while it works, so each upload is processed by
+// a single worker across all instances.
+func (cfg *apiConfig) processNextAttachment(ctx context.Context) (bool, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	attachment, err := qtx.ClaimUnprocessedAttachment(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	blurhash, err := cfg.processAttachment(ctx, qtx, attachment)
+	if err != nil && !errors.Is(err, errUnprocessableMedia) && attachment.ProcessingAttempts+1 < mediaMaxProcessingAttempts {
+		// Drop any variants written so far and come back to it later,
+		// leaving the rest of the queue to other workers meanwhile.
+		fmt.Printf("media processing: attachment %s: %v, retrying\n", attachment.ID, err)
+		tx.Rollback()
+		recordAttachmentProcessingErrorArgs := database.RecordAttachmentProcessingErrorParams{
+			ID:      attachment.ID,
+			RetryAt: sql.NullTime{Time: time.Now().UTC().Add(mediaRetryDelay), Valid: true},
+		}
+		return true, cfg.db.RecordAttachmentProcessingError(ctx, recordAttachmentProcessingErrorArgs)
+	}
+	if err != nil {
+		// Marking it processed anyway keeps a broken upload from being
+		// claimed forever; the original is still served.
+		fmt.Printf("media processing: attachment %s: %v\n", attachment.ID, err)
+	}
+
+	markAttachmentProcessedArgs := database.MarkAttachmentProcessedParams{
+		ID:       attachment.ID,
+		Blurhash: sql.NullString{String: blurhash, Valid: blurhash != ""},
+	}
+	if err := qtx.MarkAttachmentProcessed(ctx, markAttachmentProcessedArgs); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (cfg *apiConfig) processAttachment(ctx context.Context, qtx *database.Queries, attachment database.Attachment) (string, error) {
+	rc, err := cfg.blobStore.Get(ctx, attachment.StorageKey)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return "", fmt.Errorf("%w: %w", errUnprocessableMedia, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return "", err
+	}
+
+	im, err := imaging.Decode(data, cfg.mediaMaxPixels)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errUnprocessableMedia, err)
+	}
+
+	b := im.Image.Bounds()
+	for _, v := range mediaVariants {
+		if b.Dx() <= v.maxDim && b.Dy() <= v.maxDim {
+			continue
+		}
+
+		resized := imaging.Resize(im.Image, v.maxDim)
+		encoded, err := imaging.EncodeStill(resized, im.Format)
+		if err != nil {
+			return "", err
+		}
+
+		contentType := imaging.StillContentType(im.Format)
+		key := mediaVariantKey(attachment.ID, v.name, contentType)
+		if err := cfg.blobStore.Put(ctx, key, encoded, contentType); err != nil {
+			return "", err
+		}
+
+		createAttachmentVariantArgs := database.CreateAttachmentVariantParams{
+			AttachmentID: attachment.ID,
+			Name:         v.name,
+			StorageKey:   key,
+			ContentType:  contentType,
+			SizeBytes:    int64(len(encoded)),
+			Width:        int32(resized.Bounds().Dx()),
+			Height:       int32(resized.Bounds().Dy()),
+		}
+		if _, err := qtx.CreateAttachmentVariant(ctx, createAttachmentVariantArgs); err != nil {
+			return "", err
+		}
+	}
+
+	xComponents, yComponents := 4, 3
+	if b.Dy() > b.Dx() {
+		xComponents, yComponents = 3, 4
+	}
+	return imaging.Blurhash(im.Image, xComponents, yComponents)
+}
+
+// wakeMediaWorkers lets an idle worker pick up a fresh upload without waiting
+// for the next poll.
+func (cfg *apiConfig) wakeMediaWorkers() {
+	select {
+	case cfg.mediaWake <- struct{}{}:
+	default:
+	}
+}
+
+func (cfg *apiConfig) runMediaWorkers(ctx context.Context, workers int, interval time.Duration) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg.runMediaWorker(ctx, interval)
+		}()
+	}
+	wg.Wait()
+}
+
+func (cfg *apiConfig) runMediaWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			processed, err := cfg.processNextAttachment(ctx)
+			if err != nil {
+				fmt.Printf("media processing: %v\n", err)
+				break
+			}
+			if !processed {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-cfg.mediaWake:
+		}
+	}
+}
+
+// deleteAttachmentBlobs deletes the originals and variants of attachments
+// from the blob store. The rows are left to the caller.
+func (cfg *apiConfig) deleteAttachmentBlobs(ctx context.Context, attachments []database.Attachment) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(attachments))
+	for _, a := range attachments {
+		ids = append(ids, a.ID)
+	}
+	variants, err := cfg.db.GetVariantsForAttachments(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range variants {
+		if err := cfg.blobStore.Delete(ctx, v.StorageKey); err != nil {
+			return err
+		}
+	}
+	for _, a := range attachments {
+		if err := cfg.blobStore.Delete(ctx, a.StorageKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sweepNextUnattachedUpload deletes one upload that was never attached to a
+// chirp and is older than cutoff. The row stays locked until it's gone, so a
+// chirp can't attach it while its blobs are being deleted.
+func (cfg *apiConfig) sweepNextUnattachedUpload(ctx context.Context, cutoff time.Time) (bool, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	attachment, err := qtx.ClaimStaleUnattachedAttachment(ctx, cutoff)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := cfg.deleteAttachmentBlobs(ctx, []database.Attachment{attachment}); err != nil {
+		return false, err
+	}
+	if err := qtx.DeleteAttachment(ctx, attachment.ID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// runUploadSweeper deletes uploads that were never attached to a chirp within
+// maxAge of being uploaded.
+func (cfg *apiConfig) runUploadSweeper(ctx context.Context, interval, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().UTC().Add(-maxAge)
+		var n int
+		for {
+			swept, err := cfg.sweepNextUnattachedUpload(ctx, cutoff)
+			if err != nil {
+				fmt.Printf("upload sweep: %v\n", err)
+				break
+			}
+			if !swept {
+				break
+			}
+			n++
+		}
+		if n > 0 {
+			fmt.Printf("upload sweep: removed %d uploads\n", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+// returnModerationRejected answers 422 with the names of the rules that
+// rejected the chirp. The matched text isn't echoed back.
+func returnModerationRejected(w http.ResponseWriter, result moderation.Result) {
+	w.WriteHeader(422)
+
+	type returnValsError struct {
+		Msg   string   `json:"error"`
+		Rules []string `json:"rules"`
+	}
+
+	rules := []string{}
+	for _, v := range result.Violations {
+		if v.Action == moderation.ActionReject && !slices.Contains(rules, v.Rule) {
+			rules = append(rules, v.Rule)
+		}
+	}
+
+	data, err := json.Marshal(returnValsError{
+		Msg:   "Error: chirp was rejected by moderation",
+		Rules: rules,
+	})
+	if err != nil {
+		fmt.Print(err)
+		return
+	}
+	w.Write(data)
+}
+
+// recordModerationFlags stores the flag violations of result against chirpID
+// for review.
+func recordModerationFlags(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, result moderation.Result) error {
+	for _, v := range result.Violations {
+		if v.Action != moderation.ActionFlag {
+			continue
+		}
+		createModerationFlagArgs := database.CreateModerationFlagParams{
+			ChirpID: chirpID,
+			Rule:    v.Rule,
+			Match:   v.Match,
+		}
+		if err := qtx.CreateModerationFlag(ctx, createModerationFlagArgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerGetModerationFlags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 500 {
+			returnError(w, fmt.Errorf("limit must be between 1 and 500"), 400)
+			return
+		}
+		limit = n
+	}
+
+	flags, err := cfg.db.GetRecentModerationFlags(r.Context(), int32(limit))
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if flags == nil {
+		flags = []database.ModerationFlag{}
+	}
+
+	data, err := json.Marshal(flags)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
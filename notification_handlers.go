@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationFollow  = "follow"
+	notificationLike    = "like"
+	notificationReply   = "reply"
+	notificationMention = "mention"
+	notificationRechirp = "rechirp"
+)
+
+var notificationTypes = []string{
+	notificationFollow,
+	notificationLike,
+	notificationReply,
+	notificationMention,
+	notificationRechirp,
+}
+
+// maxNotificationActors is how many of a grouped notification's actors are
+// listed; actor_count still counts all of them.
+const maxNotificationActors = 5
+
+type notificationInfo struct {
+	ID         uuid.UUID   `json:"id"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	Type       string      `json:"type"`
+	ChirpID    *uuid.UUID  `json:"chirp_id"`
+	ActorIDs   []uuid.UUID `json:"actor_ids"`
+	ActorCount int         `json:"actor_count"`
+	ReadAt     *time.Time  `json:"read_at"`
+}
+
+type notificationsPage struct {
+	page[notificationInfo]
+	UnreadCount int64 `json:"unread_count"`
+}
+
+func newNotificationInfo(n database.Notification) notificationInfo {
+	info := notificationInfo{
+		ID:         n.ID,
+		CreatedAt:  n.CreatedAt,
+		UpdatedAt:  n.UpdatedAt,
+		Type:       n.Type,
+		ActorIDs:   n.ActorIds[:min(len(n.ActorIds), maxNotificationActors)],
+		ActorCount: len(n.ActorIds),
+	}
+	if n.ChirpID.Valid {
+		info.ChirpID = &n.ChirpID.UUID
+	}
+	if n.ReadAt.Valid {
+		info.ReadAt = &n.ReadAt.Time
+	}
+	return info
+}
+
+// notify records that actor did something of the given type to userID and
+// pushes it to their open streams. Likes and rechirps of the same chirp, and
+// new followers, are grouped into one unread notification; replies and
+// mentions each get their own. Nothing is recorded for the user's own
+// actions, for types they turned off, or across a block or a mute.
+func (cfg *apiConfig) notify(ctx context.Context, q *database.Queries, userID, actorID uuid.UUID, notificationType string, chirpID uuid.NullUUID) error {
+	groupKey := notificationType
+	if chirpID.Valid {
+		groupKey += ":" + chirpID.UUID.String()
+	}
+	if notificationType == notificationReply || notificationType == notificationMention {
+		groupKey += ":" + uuid.NewString()
+	}
+
+	createNotificationArgs := database.CreateNotificationParams{
+		UserID:   userID,
+		Type:     notificationType,
+		ChirpID:  chirpID,
+		GroupKey: groupKey,
+		ActorID:  actorID,
+	}
+	rows, err := q.CreateNotification(ctx, createNotificationArgs)
+	if err != nil || rows == 0 {
+		return err
+	}
+
+	var chirpIDPtr *uuid.UUID
+	if chirpID.Valid {
+		chirpIDPtr = &chirpID.UUID
+	}
+	cfg.publishEvent([]string{notificationTopic(userID)}, eventNotification, streamNotification{
+		Type:    notificationType,
+		ActorID: actorID,
+		ChirpID: chirpIDPtr,
+	})
+	return nil
+}
+
+// handlerGetNotifications pages on created_at rather than updated_at: a
+// grouped notification's updated_at moves with each new actor, which would
+// shift it between pages mid-scroll.
+func (cfg *apiConfig) handlerGetNotifications(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	getNotificationsArgs := database.GetNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		BeforeTime: cursor.Time,
+		BeforeID:   cursor.ID,
+		PageSize:   int32(limit + 1),
+	}
+	notifications, err := cfg.db.GetNotifications(r.Context(), getNotificationsArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	unread, err := cfg.db.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	items := make([]notificationInfo, 0, len(notifications))
+	for _, n := range notifications {
+		items = append(items, newNotificationInfo(n))
+	}
+
+	data, err := json.Marshal(notificationsPage{
+		page: newPage(items, limit, func(n notificationInfo) pageCursor {
+			return pageCursor{Time: n.CreatedAt, ID: n.ID}
+		}),
+		UnreadCount: unread,
+	})
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerPostNotificationRead(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	markNotificationReadArgs := database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	}
+	rows, err := cfg.db.MarkNotificationRead(r.Context(), markNotificationReadArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if rows == 0 {
+		returnError(w, fmt.Errorf("notification not found"), 404)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerPostNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	if err := cfg.db.MarkAllNotificationsRead(r.Context(), userID); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	cfg.writeNotificationSettings(w, r.Context(), userID)
+}
+
+// handlerPutNotificationSettings takes a map of notification type to whether
+// it's wanted. Types left out keep their current setting.
+func (cfg *apiConfig) handlerPutNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := map[string]bool{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	for notificationType := range params {
+		if !slices.Contains(notificationTypes, notificationType) {
+			returnError(w, fmt.Errorf("unknown notification type %q", notificationType), 400)
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	for notificationType, enabled := range params {
+		setNotificationPreferenceArgs := database.SetNotificationPreferenceParams{
+			UserID:  userID,
+			Type:    notificationType,
+			Enabled: enabled,
+		}
+		if err := qtx.SetNotificationPreference(r.Context(), setNotificationPreferenceArgs); err != nil {
+			returnError(w, err, 500)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	cfg.writeNotificationSettings(w, r.Context(), userID)
+}
+
+func (cfg *apiConfig) writeNotificationSettings(w http.ResponseWriter, ctx context.Context, userID uuid.UUID) {
+	preferences, err := cfg.db.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	// Every type is on until the user turns it off.
+	settings := map[string]bool{}
+	for _, notificationType := range notificationTypes {
+		settings[notificationType] = true
+	}
+	for _, p := range preferences {
+		settings[p.Type] = p.Enabled
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
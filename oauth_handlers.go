@@ -0,0 +1,690 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	oauthAccessTokenDuration  = time.Hour
+	oauthRefreshTokenDuration = time.Hour * 24 * 60
+	oauthCodeDuration         = time.Minute
+)
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<html>
+	<body>
+		<h1>Authorize {{.ClientName}}</h1>
+		<p>{{.ClientName}} wants to access your Chirpy account with the following permissions:</p>
+		<ul>
+			{{range .Scopes}}<li>{{.}}</li>{{end}}
+		</ul>
+		{{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+		<form method="POST" action="/oauth/authorize">
+			<input type="hidden" name="response_type" value="code">
+			<input type="hidden" name="client_id" value="{{.ClientID}}">
+			<input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+			<input type="hidden" name="scope" value="{{.Scope}}">
+			<input type="hidden" name="state" value="{{.State}}">
+			<input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+			<input type="hidden" name="code_challenge_method" value="S256">
+			<label>Email <input type="email" name="email" required></label>
+			<label>Password <input type="password" name="password" required></label>
+			<button type="submit" name="decision" value="approve">Allow</button>
+			<button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+		</form>
+	</body>
+</html>
+`))
+
+type oauthClientInfo struct {
+	ID           string    `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       string    `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+}
+
+func newOauthClientInfo(client database.OauthClient) oauthClientInfo {
+	return oauthClientInfo{
+		ID:           client.ID,
+		CreatedAt:    client.CreatedAt,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Confidential: client.SecretHash != "",
+	}
+}
+
+// returnOauthError writes errors in the format RFC 6749 section 5.2 expects
+// from the token, revocation and introspection endpoints.
+func returnOauthError(w http.ResponseWriter, code int, errCode, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	type returnValsError struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}
+
+	data, err := json.Marshal(returnValsError{Error: errCode, ErrorDescription: description})
+	if err != nil {
+		fmt.Print(err)
+		return
+	}
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerPostOauthClients(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	type requestVals struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       string   `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	if params.Name == "" {
+		returnError(w, fmt.Errorf("name is required"), 400)
+		return
+	}
+	if len(params.RedirectURIs) == 0 {
+		returnError(w, fmt.Errorf("at least one redirect uri is required"), 400)
+		return
+	}
+	for _, uri := range params.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			returnError(w, fmt.Errorf("invalid redirect uri %q", uri), 400)
+			return
+		}
+	}
+
+	scopes := strings.Join(auth.ParseScope(params.Scopes), " ")
+	if scopes == "" {
+		scopes = strings.Join(auth.SupportedScopes, " ")
+	}
+	if !auth.ScopeSubset(scopes, strings.Join(auth.SupportedScopes, " ")) {
+		returnError(w, fmt.Errorf("unsupported scope in %q", scopes), 400)
+		return
+	}
+
+	clientID := uuid.New().String()
+	secret := ""
+	secretHash := ""
+	if params.Confidential {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			returnError(w, err, 500)
+			return
+		}
+		secretHash = auth.HashToken(secret)
+	}
+
+	createOauthClientArgs := database.CreateOauthClientParams{
+		ID:           clientID,
+		UserID:       userID,
+		Name:         params.Name,
+		SecretHash:   secretHash,
+		RedirectUris: params.RedirectURIs,
+		Scopes:       scopes,
+	}
+	client, err := cfg.db.CreateOauthClient(r.Context(), createOauthClientArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	type responseVals struct {
+		oauthClientInfo
+		ClientSecret string `json:"client_secret,omitempty"`
+	}
+
+	data, err := json.Marshal(responseVals{
+		oauthClientInfo: newOauthClientInfo(client),
+		ClientSecret:    secret,
+	})
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(201)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerGetOauthClients(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	clients, err := cfg.db.GetOauthClientsForUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	responseData := []oauthClientInfo{}
+	for _, client := range clients {
+		responseData = append(responseData, newOauthClientInfo(client))
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerDeleteOauthClient(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	deleteOauthClientArgs := database.DeleteOauthClientParams{
+		ID:     r.PathValue("clientID"),
+		UserID: userID,
+	}
+	if err := cfg.db.DeleteOauthClient(r.Context(), deleteOauthClientArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+type authorizeRequest struct {
+	client      database.OauthClient
+	redirectURI string
+	// requestedRedirectURI is the redirect_uri the client sent, empty if it
+	// left it out and redirectURI is the only registered one. The token
+	// request must repeat it only if it was sent (RFC 6749 section 4.1.3).
+	requestedRedirectURI string
+	scope                string
+	state                string
+	codeChallenge        string
+}
+
+// parseAuthorizeRequest validates the parameters of an authorization request.
+// Until the client and redirect uri are known to be valid, errors must be shown
+// to the user instead of redirected, so those are returned as err. Anything
+// after that is returned as an OAuth error code to send back to the client.
+func (cfg *apiConfig) parseAuthorizeRequest(r *http.Request, values url.Values) (req authorizeRequest, oauthErr string, err error) {
+	client, err := cfg.db.GetOauthClient(r.Context(), values.Get("client_id"))
+	if err != nil {
+		return authorizeRequest{}, "", fmt.Errorf("unknown client")
+	}
+
+	redirectURI := values.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectUris) == 1 {
+		redirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		return authorizeRequest{}, "", fmt.Errorf("redirect uri is not registered for this client")
+	}
+
+	req = authorizeRequest{
+		client:               client,
+		redirectURI:          redirectURI,
+		requestedRedirectURI: values.Get("redirect_uri"),
+		state:                values.Get("state"),
+		codeChallenge:        values.Get("code_challenge"),
+	}
+
+	if values.Get("response_type") != "code" {
+		return req, "unsupported_response_type", nil
+	}
+	if req.codeChallenge == "" || values.Get("code_challenge_method") != "S256" {
+		return req, "invalid_request", nil
+	}
+
+	req.scope = strings.Join(auth.ParseScope(values.Get("scope")), " ")
+	if req.scope == "" {
+		req.scope = client.Scopes
+	}
+	if !auth.ScopeSubset(req.scope, client.Scopes) {
+		return req, "invalid_scope", nil
+	}
+
+	return req, "", nil
+}
+
+func redirectWithParams(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func redirectOauthError(w http.ResponseWriter, r *http.Request, req authorizeRequest, errCode string) {
+	params := url.Values{"error": {errCode}}
+	if req.state != "" {
+		params.Set("state", req.state)
+	}
+	redirectWithParams(w, r, req.redirectURI, params)
+}
+
+func renderConsent(w http.ResponseWriter, req authorizeRequest, code int, errMsg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	consentTemplate.Execute(w, map[string]any{
+		"ClientName":    req.client.Name,
+		"ClientID":      req.client.ID,
+		"RedirectURI":   req.requestedRedirectURI,
+		"Scope":         req.scope,
+		"Scopes":        auth.ParseScope(req.scope),
+		"State":         req.state,
+		"CodeChallenge": req.codeChallenge,
+		"Error":         errMsg,
+	})
+}
+
+func (cfg *apiConfig) handlerGetOauthAuthorize(w http.ResponseWriter, r *http.Request) {
+	req, oauthErr, err := cfg.parseAuthorizeRequest(r, r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		returnError(w, err, 400)
+		return
+	}
+	if oauthErr != "" {
+		redirectOauthError(w, r, req, oauthErr)
+		return
+	}
+
+	renderConsent(w, req, 200, "")
+}
+
+func (cfg *apiConfig) handlerPostOauthAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		returnError(w, err, 400)
+		return
+	}
+
+	req, oauthErr, err := cfg.parseAuthorizeRequest(r, r.PostForm)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		returnError(w, err, 400)
+		return
+	}
+	if oauthErr != "" {
+		redirectOauthError(w, r, req, oauthErr)
+		return
+	}
+
+	if r.PostForm.Get("decision") != "approve" {
+		redirectOauthError(w, r, req, "access_denied")
+		return
+	}
+
+	user, err := cfg.db.GetUserByEmail(r.Context(), r.PostForm.Get("email"))
+	if err != nil {
+		renderConsent(w, req, 401, "Incorrect email or password")
+		return
+	}
+	match, _, err := auth.CheckPasswordHash(r.PostForm.Get("password"), user.HashedPassword, cfg.hashParams)
+	if err != nil || !match {
+		renderConsent(w, req, 401, "Incorrect email or password")
+		return
+	}
+	if isSuspended(user) {
+		renderConsent(w, req, 403, "This account is suspended")
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := cfg.db.DeleteExpiredOauthAuthorizationCodes(r.Context()); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	createOauthAuthorizationCodeArgs := database.CreateOauthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      req.client.ID,
+		UserID:        user.ID,
+		RedirectUri:   req.requestedRedirectURI,
+		Scope:         req.scope,
+		CodeChallenge: req.codeChallenge,
+		ExpiresAt:     time.Now().Add(oauthCodeDuration),
+	}
+	if err := cfg.db.CreateOauthAuthorizationCode(r.Context(), createOauthAuthorizationCodeArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	params := url.Values{"code": {code}}
+	if req.state != "" {
+		params.Set("state", req.state)
+	}
+	redirectWithParams(w, r, req.redirectURI, params)
+}
+
+// authenticateOauthClient accepts client credentials through HTTP basic auth
+// or the request body. Public clients only send their client_id.
+func (cfg *apiConfig) authenticateOauthClient(r *http.Request) (database.OauthClient, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	client, err := cfg.db.GetOauthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, fmt.Errorf("unknown client")
+	}
+	if client.SecretHash != "" && !auth.CheckTokenHash(secret, client.SecretHash) {
+		return database.OauthClient{}, fmt.Errorf("invalid client credentials")
+	}
+
+	return client, nil
+}
+
+func (cfg *apiConfig) handlerPostOauthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		returnOauthError(w, 400, "invalid_request", err.Error())
+		return
+	}
+
+	client, err := cfg.authenticateOauthClient(r)
+	if err != nil {
+		returnOauthError(w, 401, "invalid_client", err.Error())
+		return
+	}
+
+	var userID uuid.UUID
+	var scope string
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, err := cfg.db.ConsumeOauthAuthorizationCode(r.Context(), auth.HashToken(r.PostForm.Get("code")))
+		if err != nil {
+			returnOauthError(w, 400, "invalid_grant", "unknown or already used code")
+			return
+		}
+		// An empty redirect_uri on the code means the authorization request
+		// didn't send one, so the token request needn't either.
+		redirectMismatch := code.RedirectUri != "" && code.RedirectUri != r.PostForm.Get("redirect_uri")
+		if code.ClientID != client.ID || redirectMismatch || time.Now().After(code.ExpiresAt) {
+			returnOauthError(w, 400, "invalid_grant", "code is expired or was issued to another client")
+			return
+		}
+		if !auth.CheckCodeChallenge(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+			returnOauthError(w, 400, "invalid_grant", "code verifier does not match")
+			return
+		}
+		userID = code.UserID
+		scope = code.Scope
+
+	case "refresh_token":
+		tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+		if err != nil {
+			returnOauthError(w, 500, "server_error", "")
+			return
+		}
+		defer tx.Rollback()
+		qtx := cfg.db.WithTx(tx)
+
+		// Refresh tokens are rotated on every use. Revoking the old one in
+		// the same statement that checks it means only one of several
+		// concurrent requests with the same token gets through; the
+		// transaction leaves it alone if the request is refused below.
+		tokenInfo, err := qtx.ConsumeRefreshToken(r.Context(), r.PostForm.Get("refresh_token"))
+		if err != nil || tokenInfo.ClientID.String != client.ID {
+			returnOauthError(w, 400, "invalid_grant", "invalid refresh token")
+			return
+		}
+
+		scope = tokenInfo.Scope
+		if requested := r.PostForm.Get("scope"); requested != "" {
+			if !auth.ScopeSubset(requested, tokenInfo.Scope) {
+				returnOauthError(w, 400, "invalid_scope", "")
+				return
+			}
+			scope = strings.Join(auth.ParseScope(requested), " ")
+		}
+
+		if err := tx.Commit(); err != nil {
+			returnOauthError(w, 500, "server_error", "")
+			return
+		}
+		userID = tokenInfo.UserID
+
+	default:
+		returnOauthError(w, 400, "unsupported_grant_type", "")
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userID)
+	if err != nil {
+		returnOauthError(w, 500, "server_error", "")
+		return
+	}
+	if isSuspended(user) {
+		returnOauthError(w, 400, "invalid_grant", "account is suspended")
+		return
+	}
+
+	accessToken, err := auth.MakeScopedJWT(userID, cfg.jwtSecret, oauthAccessTokenDuration, client.ID, scope)
+	if err != nil {
+		returnOauthError(w, 500, "server_error", "")
+		return
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		returnOauthError(w, 500, "server_error", "")
+		return
+	}
+
+	createClientRefreshTokenArgs := database.CreateClientRefreshTokenParams{
+		Token:     refreshToken,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(oauthRefreshTokenDuration),
+		ClientID:  sql.NullString{String: client.ID, Valid: true},
+		Scope:     scope,
+	}
+	if _, err := cfg.db.CreateClientRefreshToken(r.Context(), createClientRefreshTokenArgs); err != nil {
+		returnOauthError(w, 500, "server_error", "")
+		return
+	}
+
+	type responseVals struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+
+	data, err := json.Marshal(responseVals{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenDuration.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	})
+	if err != nil {
+		returnOauthError(w, 500, "server_error", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+// handlerPostOauthRevoke implements RFC 7009. Access tokens are stateless JWTs
+// that expire within the hour, so only refresh tokens can be revoked.
+func (cfg *apiConfig) handlerPostOauthRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		returnOauthError(w, 400, "invalid_request", err.Error())
+		return
+	}
+
+	client, err := cfg.authenticateOauthClient(r)
+	if err != nil {
+		returnOauthError(w, 401, "invalid_client", err.Error())
+		return
+	}
+
+	token := r.PostForm.Get("token")
+
+	if _, err := auth.ParseJWT(token, cfg.jwtSecret); err == nil {
+		returnOauthError(w, 400, "unsupported_token_type", "access tokens can't be revoked, revoke the refresh token instead")
+		return
+	}
+
+	tokenInfo, err := cfg.db.GetToken(r.Context(), token)
+	if err == nil && tokenInfo.ClientID.String == client.ID {
+		if err := cfg.db.RevokeToken(r.Context(), token); err != nil {
+			returnOauthError(w, 500, "server_error", "")
+			return
+		}
+	}
+
+	// Unknown and already revoked tokens are not an error.
+	w.WriteHeader(200)
+}
+
+// handlerPostOauthIntrospect implements RFC 7662. Clients can only introspect
+// their own tokens, anything else is reported as inactive.
+func (cfg *apiConfig) handlerPostOauthIntrospect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		returnOauthError(w, 400, "invalid_request", err.Error())
+		return
+	}
+
+	client, err := cfg.authenticateOauthClient(r)
+	if err != nil {
+		returnOauthError(w, 401, "invalid_client", err.Error())
+		return
+	}
+
+	type responseVals struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+		Exp       int64  `json:"exp,omitempty"`
+		Iat       int64  `json:"iat,omitempty"`
+		Sub       string `json:"sub,omitempty"`
+		Iss       string `json:"iss,omitempty"`
+	}
+
+	responseData := responseVals{}
+	token := r.PostForm.Get("token")
+
+	if claims, err := auth.ParseJWT(token, cfg.jwtSecret); err == nil {
+		if claims.ClientID == client.ID {
+			responseData = responseVals{
+				Active:    true,
+				Scope:     claims.Scope,
+				ClientID:  claims.ClientID,
+				TokenType: "Bearer",
+				Exp:       claims.ExpiresAt.Unix(),
+				Iat:       claims.IssuedAt.Unix(),
+				Sub:       claims.Subject,
+				Iss:       claims.Issuer,
+			}
+		}
+	} else if tokenInfo, err := cfg.db.GetToken(r.Context(), token); err == nil {
+		if !tokenInfo.Expired && tokenInfo.ClientID.String == client.ID {
+			responseData = responseVals{
+				Active:    true,
+				Scope:     tokenInfo.Scope,
+				ClientID:  tokenInfo.ClientID.String,
+				TokenType: "refresh_token",
+				Exp:       tokenInfo.ExpiresAt.Unix(),
+				Iat:       tokenInfo.CreatedAt.Unix(),
+				Sub:       tokenInfo.UserID.String(),
+				Iss:       "chirpy",
+			}
+		}
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnOauthError(w, 500, "server_error", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(200)
+	w.Write(data)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/oidc"
+)
+
+func (cfg *apiConfig) oidcProvider(r *http.Request) (*oidc.Provider, error) {
+	name := r.PathValue("provider")
+	provider, ok := cfg.oidcProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown identity provider %q", name)
+	}
+	return provider, nil
+}
+
+func (cfg *apiConfig) handlerGetOidcLogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	provider, err := cfg.oidcProvider(r)
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	verifier, err := oidc.GenerateCodeVerifier()
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	// Abandoned logins are cleaned up here rather than by a separate job.
+	if err := cfg.db.DeleteExpiredOidcAuthRequests(r.Context()); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	createOidcAuthRequestArgs := database.CreateOidcAuthRequestParams{
+		State:        state,
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(10 * time.Minute),
+	}
+	if err := cfg.db.CreateOidcAuthRequest(r.Context(), createOidcAuthRequestArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, oidc.CodeChallengeS256(verifier))
+	if err != nil {
+		returnError(w, err, 502)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (cfg *apiConfig) handlerGetOidcCallback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	provider, err := cfg.oidcProvider(r)
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		returnError(w, fmt.Errorf("identity provider returned %s: %s", e, query.Get("error_description")), 401)
+		return
+	}
+
+	// The state row is deleted as it is read so a callback can't be replayed.
+	authRequest, err := cfg.db.ConsumeOidcAuthRequest(r.Context(), query.Get("state"))
+	if err != nil {
+		returnError(w, fmt.Errorf("unknown or already used state"), 401)
+		return
+	}
+	if authRequest.Provider != provider.Name() || time.Now().After(authRequest.ExpiresAt) {
+		returnError(w, fmt.Errorf("login request expired"), 401)
+		return
+	}
+
+	tokens, err := provider.Exchange(r.Context(), query.Get("code"), authRequest.CodeVerifier)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(r.Context(), tokens.IDToken, authRequest.Nonce)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	user, err := cfg.linkIdentity(r, provider.Name(), claims)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	cfg.writeLoginResponse(w, r, user)
+}
+
+// linkIdentity returns the user an external identity belongs to. Identities
+// seen for the first time are linked to the user with the same verified email,
+// or to a new passwordless user.
+func (cfg *apiConfig) linkIdentity(r *http.Request, provider string, claims *oidc.IDTokenClaims) (database.User, error) {
+	getUserByIdentityArgs := database.GetUserByIdentityParams{
+		Provider: provider,
+		Subject:  claims.Subject,
+	}
+	user, err := cfg.db.GetUserByIdentity(r.Context(), getUserByIdentityArgs)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return database.User{}, fmt.Errorf("identity provider did not return a verified email")
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	user, err = qtx.GetUserByEmail(r.Context(), claims.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = qtx.CreateUserWithoutPassword(r.Context(), claims.Email)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	createUserIdentityArgs := database.CreateUserIdentityParams{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+	if _, err := qtx.CreateUserIdentity(r.Context(), createUserIdentityArgs); err != nil {
+		return database.User{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+type webhookEndpointInfo struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+}
+
+func newWebhookEndpointInfo(endpoint database.WebhookEndpoint) webhookEndpointInfo {
+	return webhookEndpointInfo{
+		ID:        endpoint.ID,
+		CreatedAt: endpoint.CreatedAt,
+		URL:       endpoint.Url,
+		Events:    endpoint.Events,
+		Active:    endpoint.Active,
+	}
+}
+
+func (cfg *apiConfig) handlerPostWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeWebhooks)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	type requestVals struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	u, err := url.Parse(params.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		returnError(w, fmt.Errorf("url must be an absolute http(s) url"), 400)
+		return
+	}
+
+	events := []string{}
+	for _, e := range params.Events {
+		if !slices.Contains(webhooks.SupportedEvents, e) {
+			returnError(w, fmt.Errorf("unsupported event %q", e), 400)
+			return
+		}
+		if !slices.Contains(events, e) {
+			events = append(events, e)
+		}
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	createWebhookEndpointArgs := database.CreateWebhookEndpointParams{
+		UserID: userID,
+		Url:    params.URL,
+		Secret: secret,
+		Events: events,
+	}
+	endpoint, err := cfg.db.CreateWebhookEndpoint(r.Context(), createWebhookEndpointArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	// The secret is only ever shown here.
+	type responseVals struct {
+		webhookEndpointInfo
+		Secret string `json:"secret"`
+	}
+
+	data, err := json.Marshal(responseVals{
+		webhookEndpointInfo: newWebhookEndpointInfo(endpoint),
+		Secret:              endpoint.Secret,
+	})
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(201)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerGetWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeWebhooks)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	endpoints, err := cfg.db.GetWebhookEndpointsForUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	responseData := []webhookEndpointInfo{}
+	for _, endpoint := range endpoints {
+		responseData = append(responseData, newWebhookEndpointInfo(endpoint))
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeWebhooks)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	deleteWebhookEndpointArgs := database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: userID,
+	}
+	if err := cfg.db.DeleteWebhookEndpoint(r.Context(), deleteWebhookEndpointArgs); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeWebhooks)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	endpoint, err := cfg.db.GetWebhookEndpoint(r.Context(), endpointID)
+	if err != nil || endpoint.UserID != userID {
+		returnError(w, fmt.Errorf("webhook endpoint not found"), 404)
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 500 {
+			returnError(w, fmt.Errorf("limit must be between 1 and 500"), 400)
+			return
+		}
+		limit = n
+	}
+
+	getWebhookDeliveriesForEndpointArgs := database.GetWebhookDeliveriesForEndpointParams{
+		EndpointID: endpointID,
+		Limit:      int32(limit),
+	}
+	deliveries, err := cfg.db.GetWebhookDeliveriesForEndpoint(r.Context(), getWebhookDeliveriesForEndpointArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	type deliveryInfo struct {
+		ID             uuid.UUID  `json:"id"`
+		CreatedAt      time.Time  `json:"created_at"`
+		EventID        uuid.UUID  `json:"event_id"`
+		Status         string     `json:"status"`
+		Attempts       int32      `json:"attempts"`
+		NextAttemptAt  *time.Time `json:"next_attempt_at"`
+		LastStatusCode int32      `json:"last_status_code"`
+		LastError      string     `json:"last_error"`
+		DeliveredAt    *time.Time `json:"delivered_at"`
+	}
+
+	responseData := []deliveryInfo{}
+	for _, d := range deliveries {
+		info := deliveryInfo{
+			ID:             d.ID,
+			CreatedAt:      d.CreatedAt,
+			EventID:        d.OutboxID,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			LastStatusCode: d.LastStatusCode,
+			LastError:      d.LastError,
+		}
+		if d.Status == deliveryStatusPending {
+			info.NextAttemptAt = &d.NextAttemptAt
+		}
+		if d.DeliveredAt.Valid {
+			info.DeliveredAt = &d.DeliveredAt.Time
+		}
+		responseData = append(responseData, info)
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
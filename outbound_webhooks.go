@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	deliveryStatusPending   = "pending"
+	deliveryStatusSucceeded = "succeeded"
+	deliveryStatusDead      = "dead"
+
+	outboxBatchSize   = 100
+	deliveryBatchSize = 20
+	// deliveryLeaseMargin covers the database work around each send.
+	deliveryLeaseMargin = time.Minute
+)
+
+// enqueueOutboxEvent records an event about a chirp by authorID for
+// subscribers. It must be called with the same transaction that made the
+// change so the event is only published if the change commits.
+func enqueueOutboxEvent(ctx context.Context, qtx *database.Queries, eventType string, authorID uuid.UUID, data any) error {
+	type payload struct {
+		Type      string    `json:"type"`
+		CreatedAt time.Time `json:"created_at"`
+		Data      any       `json:"data"`
+	}
+
+	body, err := json.Marshal(payload{
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	createOutboxEventArgs := database.CreateOutboxEventParams{
+		EventType: eventType,
+		Payload:   body,
+		AuthorID:  uuid.NullUUID{UUID: authorID, Valid: true},
+	}
+	_, err = qtx.CreateOutboxEvent(ctx, createOutboxEventArgs)
+	return err
+}
+
+// dispatchOutbox fans undispatched outbox events out into one delivery per
+// matching endpoint, leaving out endpoints whose owner has a block either way
+// with the event's author. SKIP LOCKED lets several instances share the work.
+func (cfg *apiConfig) dispatchOutbox(ctx context.Context) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	events, err := qtx.ClaimUndispatchedOutboxEvents(ctx, outboxBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		getActiveWebhookEndpointsForEventArgs := database.GetActiveWebhookEndpointsForEventParams{
+			EventType: event.EventType,
+			AuthorID:  event.AuthorID,
+		}
+		endpoints, err := qtx.GetActiveWebhookEndpointsForEvent(ctx, getActiveWebhookEndpointsForEventArgs)
+		if err != nil {
+			return err
+		}
+		for _, endpoint := range endpoints {
+			createWebhookDeliveryArgs := database.CreateWebhookDeliveryParams{
+				EndpointID: endpoint.ID,
+				OutboxID:   event.ID,
+			}
+			if err := qtx.CreateWebhookDelivery(ctx, createWebhookDeliveryArgs); err != nil {
+				return err
+			}
+		}
+		if err := qtx.MarkOutboxEventDispatched(ctx, event.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// deliverDue sends deliveries whose next attempt is due. The claimed batch is
+// sent one delivery after another, so the lease lasts long enough for every
+// send to time out; a crashed instance's work is picked up again once it
+// runs out.
+func (cfg *apiConfig) deliverDue(ctx context.Context) error {
+	lease := time.Duration(deliveryBatchSize)*cfg.webhookSender.Client.Timeout + deliveryLeaseMargin
+	claimDueWebhookDeliveriesArgs := database.ClaimDueWebhookDeliveriesParams{
+		LockedUntil: sql.NullTime{Time: time.Now().UTC().Add(lease), Valid: true},
+		BatchSize:   deliveryBatchSize,
+	}
+	deliveries, err := cfg.db.ClaimDueWebhookDeliveries(ctx, claimDueWebhookDeliveriesArgs)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		if err := cfg.deliver(ctx, delivery); err != nil {
+			fmt.Printf("webhook delivery %s: %v\n", delivery.ID, err)
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) deliver(ctx context.Context, delivery database.WebhookDelivery) error {
+	endpoint, err := cfg.db.GetWebhookEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		return err
+	}
+	event, err := cfg.db.GetOutboxEvent(ctx, delivery.OutboxID)
+	if err != nil {
+		return err
+	}
+
+	code, sendErr := cfg.webhookSender.Send(ctx, endpoint.Url, endpoint.Secret, event.EventType, delivery.ID.String(), event.Payload)
+	if sendErr == nil {
+		markWebhookDeliverySucceededArgs := database.MarkWebhookDeliverySucceededParams{
+			ID:             delivery.ID,
+			LastStatusCode: int32(code),
+		}
+		return cfg.db.MarkWebhookDeliverySucceeded(ctx, markWebhookDeliverySucceededArgs)
+	}
+
+	attempts := int(delivery.Attempts) + 1
+	status := deliveryStatusPending
+	if attempts >= cfg.webhookSender.MaxAttempts {
+		status = deliveryStatusDead
+	}
+
+	markWebhookDeliveryFailedArgs := database.MarkWebhookDeliveryFailedParams{
+		ID:             delivery.ID,
+		Status:         status,
+		NextAttemptAt:  time.Now().UTC().Add(cfg.webhookSender.Backoff(attempts)),
+		LastStatusCode: int32(code),
+		LastError:      sendErr.Error(),
+	}
+	return cfg.db.MarkWebhookDeliveryFailed(ctx, markWebhookDeliveryFailedArgs)
+}
+
+func (cfg *apiConfig) runWebhookWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := cfg.dispatchOutbox(ctx); err != nil {
+			fmt.Printf("webhook outbox: %v\n", err)
+		}
+		if err := cfg.deliverDue(ctx); err != nil {
+			fmt.Printf("webhook deliveries: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
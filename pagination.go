@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageCursor is a position in a list ordered newest first by time, with the
+// id breaking ties. A page holds the items strictly after it.
+type pageCursor struct {
+	Time time.Time
+	ID   uuid.UUID
+}
+
+// pageStart sorts before everything, so it's used when no cursor is given.
+var pageStart = pageCursor{
+	Time: time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC),
+	ID:   uuid.Max,
+}
+
+type page[T any] struct {
+	Items []T `json:"items"`
+	// NextCursor is null on the last page.
+	NextCursor *string `json:"next_cursor"`
+}
+
+func (c pageCursor) encode() string {
+	raw := c.Time.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor")
+	}
+	timeString, idString, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return pageCursor{}, fmt.Errorf("invalid cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, timeString)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor")
+	}
+	id, err := uuid.Parse(idString)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return pageCursor{Time: t, ID: id}, nil
+}
+
+// parsePage reads the ?limit= and ?cursor= query parameters.
+func parsePage(r *http.Request) (pageCursor, int, error) {
+	limit := defaultPageSize
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxPageSize {
+			return pageCursor{}, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		limit = n
+	}
+
+	cursor := pageStart
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		var err error
+		cursor, err = decodeCursor(c)
+		if err != nil {
+			return pageCursor{}, 0, err
+		}
+	}
+	return cursor, limit, nil
+}
+
+// newPage builds a page from items fetched with one more row than limit, which
+// tells us whether there is a next page without a separate count.
+func newPage[T any](items []T, limit int, key func(T) pageCursor) page[T] {
+	p := page[T]{Items: items}
+	if len(items) > limit {
+		p.Items = items[:limit]
+		next := key(p.Items[limit-1]).encode()
+		p.NextCursor = &next
+	}
+	if p.Items == nil {
+		p.Items = []T{}
+	}
+	return p
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 50
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+)
+
+// When counts are shown before the poll closes. The author can always see
+// them.
+const (
+	pollResultsAlways     = "always"
+	pollResultsAfterVote  = "after_vote"
+	pollResultsAfterClose = "after_close"
+)
+
+var pollResultsVisibilities = []string{pollResultsAlways, pollResultsAfterVote, pollResultsAfterClose}
+
+type pollRequest struct {
+	Options           []string  `json:"options"`
+	ClosesAt          time.Time `json:"closes_at"`
+	MultipleChoice    bool      `json:"multiple_choice"`
+	ResultsVisibility string    `json:"results_visibility"`
+}
+
+type pollOptionInfo struct {
+	ID    uuid.UUID `json:"id"`
+	Text  string    `json:"text"`
+	Votes *int64    `json:"votes"`
+}
+
+type pollInfo struct {
+	Options           []pollOptionInfo `json:"options"`
+	ClosesAt          time.Time        `json:"closes_at"`
+	Closed            bool             `json:"closed"`
+	MultipleChoice    bool             `json:"multiple_choice"`
+	ResultsVisibility string           `json:"results_visibility"`
+	ResultsVisible    bool             `json:"results_visible"`
+	Voters            *int64           `json:"voters"`
+	// OwnVotes is null until the viewer has voted.
+	OwnVotes []uuid.UUID `json:"own_votes"`
+}
+
+// checkPoll validates a poll and runs its options through moderation,
+// returning the option texts to store.
+func (cfg *apiConfig) checkPoll(w http.ResponseWriter, poll *pollRequest) ([]string, bool) {
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		returnError(w, fmt.Errorf("polls need between %d and %d options", minPollOptions, maxPollOptions), 400)
+		return nil, false
+	}
+
+	if poll.ResultsVisibility == "" {
+		poll.ResultsVisibility = pollResultsAlways
+	}
+	if !slices.Contains(pollResultsVisibilities, poll.ResultsVisibility) {
+		returnError(w, fmt.Errorf("results_visibility must be one of %s", strings.Join(pollResultsVisibilities, ", ")), 400)
+		return nil, false
+	}
+
+	// closes_at is stored without a zone, so it has to be in UTC to mean
+	// the instant the client sent.
+	poll.ClosesAt = poll.ClosesAt.UTC()
+	untilClose := time.Until(poll.ClosesAt)
+	if untilClose < minPollDuration || untilClose > maxPollDuration {
+		returnError(w, fmt.Errorf("closes_at must be between %v and %v from now", minPollDuration, maxPollDuration), 400)
+		return nil, false
+	}
+
+	options := make([]string, 0, len(poll.Options))
+	for _, option := range poll.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || len(option) > maxPollOptionLength {
+			returnError(w, fmt.Errorf("poll options must be between 1 and %d characters", maxPollOptionLength), 400)
+			return nil, false
+		}
+		if slices.Contains(options, option) {
+			returnError(w, fmt.Errorf("poll options must be unique"), 400)
+			return nil, false
+		}
+
+		moderated := cfg.moderator.Check(option)
+		if moderated.Rejected() {
+			returnModerationRejected(w, moderated)
+			return nil, false
+		}
+		options = append(options, moderated.Text)
+	}
+	return options, true
+}
+
+func createPoll(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, poll pollRequest, options []string) error {
+	createPollArgs := database.CreatePollParams{
+		ChirpID:           chirpID,
+		ClosesAt:          poll.ClosesAt.UTC(),
+		MultipleChoice:    poll.MultipleChoice,
+		ResultsVisibility: poll.ResultsVisibility,
+	}
+	if _, err := qtx.CreatePoll(ctx, createPollArgs); err != nil {
+		return err
+	}
+
+	for i, text := range options {
+		createPollOptionArgs := database.CreatePollOptionParams{
+			ChirpID:  chirpID,
+			Position: int32(i),
+			Text:     text,
+		}
+		if _, err := qtx.CreatePollOption(ctx, createPollOptionArgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pollInfosForChirps loads the polls on chirps as viewer sees them. Counts are
+// left null when the poll's settings hide them from viewer.
+func pollInfosForChirps(ctx context.Context, q *database.Queries, viewer uuid.NullUUID, chirps []database.Chirp) (map[uuid.UUID]*pollInfo, error) {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	authors := map[uuid.UUID]uuid.UUID{}
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+		authors[chirp.ID] = chirp.UserID
+	}
+
+	polls, err := q.GetPollsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byChirp := map[uuid.UUID]*pollInfo{}
+	if len(polls) == 0 {
+		return byChirp, nil
+	}
+
+	options, err := q.GetPollOptionsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	ownVotes := map[uuid.UUID][]uuid.UUID{}
+	if viewer.Valid {
+		getPollVotesForUserArgs := database.GetPollVotesForUserParams{
+			UserID:   viewer.UUID,
+			ChirpIds: ids,
+		}
+		votes, err := q.GetPollVotesForUser(ctx, getPollVotesForUserArgs)
+		if err != nil {
+			return nil, err
+		}
+		for _, vote := range votes {
+			ownVotes[vote.ChirpID] = vote.OptionIds
+		}
+	}
+
+	now := time.Now()
+	for _, poll := range polls {
+		own, voted := ownVotes[poll.ChirpID]
+		closed := !now.Before(poll.ClosesAt)
+
+		visible := closed || poll.ResultsVisibility == pollResultsAlways ||
+			(poll.ResultsVisibility == pollResultsAfterVote && voted) ||
+			(viewer.Valid && viewer.UUID == authors[poll.ChirpID])
+
+		info := &pollInfo{
+			Options:           []pollOptionInfo{},
+			ClosesAt:          poll.ClosesAt,
+			Closed:            closed,
+			MultipleChoice:    poll.MultipleChoice,
+			ResultsVisibility: poll.ResultsVisibility,
+			ResultsVisible:    visible,
+			OwnVotes:          own,
+		}
+		if visible {
+			info.Voters = &poll.Voters
+		}
+		byChirp[poll.ChirpID] = info
+	}
+
+	for _, option := range options {
+		info, ok := byChirp[option.ChirpID]
+		if !ok {
+			continue
+		}
+		optionInfo := pollOptionInfo{
+			ID:   option.ID,
+			Text: option.Text,
+		}
+		if info.ResultsVisible {
+			optionInfo.Votes = &option.Votes
+		}
+		info.Options = append(info.Options, optionInfo)
+	}
+
+	return byChirp, nil
+}
+
+func (cfg *apiConfig) handlerPostPollVote(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeChirpsWrite)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	type requestVals struct {
+		OptionIDs []uuid.UUID `json:"option_ids"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if isSuspended(user) {
+		returnSuspended(w, user)
+		return
+	}
+
+	chirp, _, ok := cfg.visibleChirp(w, r)
+	if !ok {
+		return
+	}
+
+	poll, err := cfg.db.GetPoll(r.Context(), chirp.ID)
+	if err != nil {
+		returnError(w, fmt.Errorf("chirp has no poll"), 404)
+		return
+	}
+	if !time.Now().Before(poll.ClosesAt) {
+		returnError(w, fmt.Errorf("poll is closed"), 409)
+		return
+	}
+
+	options, err := cfg.db.GetPollOptions(r.Context(), chirp.ID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	choices := []uuid.UUID{}
+	for _, id := range params.OptionIDs {
+		if slices.Contains(choices, id) {
+			continue
+		}
+		if !slices.ContainsFunc(options, func(o database.PollOption) bool { return o.ID == id }) {
+			returnError(w, fmt.Errorf("option %s is not part of this poll", id), 400)
+			return
+		}
+		choices = append(choices, id)
+	}
+	if len(choices) == 0 {
+		returnError(w, fmt.Errorf("pick at least one option"), 400)
+		return
+	}
+	if len(choices) > 1 && !poll.MultipleChoice {
+		returnError(w, fmt.Errorf("this poll allows a single choice"), 400)
+		return
+	}
+
+	createPollVoteArgs := database.CreatePollVoteParams{
+		ChirpID:   chirp.ID,
+		UserID:    userID,
+		OptionIds: choices,
+	}
+	n, err := cfg.db.CreatePollVote(r.Context(), createPollVoteArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if n == 0 {
+		returnError(w, fmt.Errorf("you have already voted in this poll"), 409)
+		return
+	}
+
+	info, err := chirpInfoFor(r.Context(), cfg.db, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
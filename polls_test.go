@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/moderation"
+)
+
+func TestCheckPollClosesAtUTC(t *testing.T) {
+	moderator, err := moderation.NewModerator("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &apiConfig{moderator: moderator}
+
+	closesAt := time.Now().Add(24 * time.Hour).In(time.FixedZone("UTC+5", 5*60*60))
+	poll := pollRequest{Options: []string{"yes", "no"}, ClosesAt: closesAt}
+
+	w := httptest.NewRecorder()
+	if _, ok := cfg.checkPoll(w, &poll); !ok {
+		t.Fatalf("expected the poll to be valid, got %d: %s", w.Code, w.Body)
+	}
+	if poll.ClosesAt.Location() != time.UTC {
+		t.Errorf("expected closes_at in UTC, got %v", poll.ClosesAt.Location())
+	}
+	if !poll.ClosesAt.Equal(closesAt) {
+		t.Errorf("expected the same instant %v, got %v", closesAt, poll.ClosesAt)
+	}
+}
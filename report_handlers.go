@@ -0,0 +1,674 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "misinformation", "other"}
+
+const (
+	reportStatusOpen      = "open"
+	reportStatusInReview  = "in_review"
+	reportStatusResolved  = "resolved"
+	reportStatusDismissed = "dismissed"
+)
+
+// reportTransitions lists the statuses each status may move to by hand.
+// Reports are resolved through the resolve endpoint so the outcome is
+// always recorded.
+var reportTransitions = map[string][]string{
+	reportStatusOpen:      {reportStatusInReview, reportStatusDismissed},
+	reportStatusInReview:  {reportStatusOpen, reportStatusDismissed},
+	reportStatusResolved:  {reportStatusOpen},
+	reportStatusDismissed: {reportStatusOpen},
+}
+
+const (
+	resolutionNone      = "none"
+	resolutionHideChirp = "hide_chirp"
+	resolutionWarn      = "warn"
+	resolutionSuspend   = "suspend"
+)
+
+var resolutionActions = []string{resolutionNone, resolutionHideChirp, resolutionWarn, resolutionSuspend}
+
+const maxSuspension = time.Hour * 24 * 365
+
+type reportInfo struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ChirpID    uuid.UUID  `json:"chirp_id"`
+	ReporterID uuid.UUID  `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	Assignee   *string    `json:"assignee"`
+	Resolution *string    `json:"resolution"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+// warningInfo is a warning as the warned user sees it. Who gave it stays
+// private.
+type warningInfo struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ChirpID   *uuid.UUID `json:"chirp_id"`
+	Note      string     `json:"note"`
+}
+
+func newWarningInfo(action database.ModerationAction) warningInfo {
+	info := warningInfo{
+		ID:        action.ID,
+		CreatedAt: action.CreatedAt,
+		Note:      action.Note,
+	}
+	if action.ChirpID.Valid {
+		info.ChirpID = &action.ChirpID.UUID
+	}
+	return info
+}
+
+func newReportInfo(report database.Report) reportInfo {
+	info := reportInfo{
+		ID:         report.ID,
+		CreatedAt:  report.CreatedAt,
+		UpdatedAt:  report.UpdatedAt,
+		ChirpID:    report.ChirpID,
+		ReporterID: report.ReporterID,
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     report.Status,
+	}
+	if report.Assignee.Valid {
+		info.Assignee = &report.Assignee.String
+	}
+	if report.Resolution.Valid {
+		info.Resolution = &report.Resolution.String
+	}
+	if report.ResolvedAt.Valid {
+		info.ResolvedAt = &report.ResolvedAt.Time
+	}
+	return info
+}
+
+func isSuspended(user database.User) bool {
+	return user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now().UTC())
+}
+
+func returnSuspended(w http.ResponseWriter, user database.User) {
+	returnError(w, fmt.Errorf("account is suspended until %s", user.SuspendedUntil.Time.Format(time.RFC3339)), 403)
+}
+
+// requireModerator checks for the admin API key and the Moderator header that
+// names who is acting, which goes into the audit trail.
+func (cfg *apiConfig) requireModerator(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !cfg.requireAdmin(w, r) {
+		return "", false
+	}
+
+	moderator := strings.TrimSpace(r.Header.Get("Moderator"))
+	if moderator == "" {
+		returnError(w, fmt.Errorf("missing Moderator header"), 400)
+		return "", false
+	}
+	return moderator, true
+}
+
+func recordModerationAction(ctx context.Context, qtx *database.Queries, moderator, action string, report database.Report, userID uuid.NullUUID, note string) error {
+	createModerationActionArgs := database.CreateModerationActionParams{
+		Moderator: moderator,
+		Action:    action,
+		ReportID:  uuid.NullUUID{UUID: report.ID, Valid: true},
+		ChirpID:   uuid.NullUUID{UUID: report.ChirpID, Valid: true},
+		UserID:    userID,
+		Note:      note,
+	}
+	return qtx.CreateModerationAction(ctx, createModerationActionArgs)
+}
+
+func (cfg *apiConfig) handlerPostChirpReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	type requestVals struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	if !slices.Contains(reportReasons, params.Reason) {
+		returnError(w, fmt.Errorf("reason must be one of %s", strings.Join(reportReasons, ", ")), 400)
+		return
+	}
+	if len(params.Details) > 1000 {
+		returnError(w, fmt.Errorf("details are too long"), 400)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil || chirp.HiddenAt.Valid {
+		returnError(w, fmt.Errorf("chirp not found"), 404)
+		return
+	}
+	if chirp.UserID == userID {
+		returnError(w, fmt.Errorf("cannot report your own chirp"), 400)
+		return
+	}
+
+	createReportArgs := database.CreateReportParams{
+		ChirpID:    chirpID,
+		ReporterID: userID,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	}
+	report, err := cfg.db.CreateReport(r.Context(), createReportArgs)
+	if errors.Is(err, sql.ErrNoRows) {
+		returnError(w, fmt.Errorf("chirp was already reported"), 409)
+		return
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(newReportInfo(report))
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(201)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerGetReports(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := cfg.requireModerator(w, r); !ok {
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 500 {
+			returnError(w, fmt.Errorf("limit must be between 1 and 500"), 400)
+			return
+		}
+		limit = n
+	}
+
+	var reports []database.Report
+	var err error
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		getReportsByStatusArgs := database.GetReportsByStatusParams{
+			Status: status,
+			Limit:  int32(limit),
+		}
+		reports, err = cfg.db.GetReportsByStatus(r.Context(), getReportsByStatusArgs)
+	} else {
+		reports, err = cfg.db.GetRecentReports(r.Context(), int32(limit))
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	responseData := []reportInfo{}
+	for _, report := range reports {
+		responseData = append(responseData, newReportInfo(report))
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerGetReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := cfg.requireModerator(w, r); !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	report, err := cfg.db.GetReport(r.Context(), reportID)
+	if err != nil {
+		returnError(w, fmt.Errorf("report not found"), 404)
+		return
+	}
+
+	// Moderators need to see the chirp even after it was hidden or deleted.
+	chirp, err := cfg.db.GetChirpIncludingDeleted(r.Context(), report.ChirpID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	info, err := chirpInfoFor(r.Context(), cfg.db, uuid.NullUUID{}, chirp)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	actions, err := cfg.db.GetModerationActionsForReport(r.Context(), uuid.NullUUID{UUID: reportID, Valid: true})
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if actions == nil {
+		actions = []database.ModerationAction{}
+	}
+
+	type responseVals struct {
+		reportInfo
+		Chirp   chirpInfo                   `json:"chirp"`
+		Hidden  bool                        `json:"chirp_hidden"`
+		Deleted bool                        `json:"chirp_deleted"`
+		Actions []database.ModerationAction `json:"actions"`
+	}
+
+	data, err := json.Marshal(responseVals{
+		reportInfo: newReportInfo(report),
+		Chirp:      info,
+		Hidden:     chirp.HiddenAt.Valid,
+		Deleted:    chirp.DeletedAt.Valid,
+		Actions:    actions,
+	})
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+// updateReport locks the report, lets change modify it and writes the result
+// together with its audit entry.
+func (cfg *apiConfig) updateReport(ctx context.Context, reportID uuid.UUID, change func(qtx *database.Queries, report *database.Report) error) (database.Report, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Report{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	report, err := qtx.LockReport(ctx, reportID)
+	if err != nil {
+		return database.Report{}, err
+	}
+
+	if err := change(qtx, &report); err != nil {
+		return database.Report{}, err
+	}
+
+	updateReportArgs := database.UpdateReportParams{
+		ID:         report.ID,
+		Status:     report.Status,
+		Assignee:   report.Assignee,
+		Resolution: report.Resolution,
+		ResolvedAt: report.ResolvedAt,
+	}
+	report, err = qtx.UpdateReport(ctx, updateReportArgs)
+	if err != nil {
+		return database.Report{}, err
+	}
+
+	return report, tx.Commit()
+}
+
+type reportError struct {
+	code int
+	err  error
+}
+
+func (e *reportError) Error() string { return e.err.Error() }
+
+func writeReportResult(w http.ResponseWriter, report database.Report, err error) {
+	var rerr *reportError
+	if errors.As(err, &rerr) {
+		returnError(w, rerr.err, rerr.code)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		returnError(w, fmt.Errorf("report not found"), 404)
+		return
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(newReportInfo(report))
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerPostReportAssign(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	type requestVals struct {
+		Assignee string `json:"assignee"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	// Without an assignee the report is taken by whoever is asking.
+	assignee := strings.TrimSpace(params.Assignee)
+	if assignee == "" {
+		assignee = moderator
+	}
+
+	report, err := cfg.updateReport(r.Context(), reportID, func(qtx *database.Queries, report *database.Report) error {
+		if report.Status == reportStatusResolved || report.Status == reportStatusDismissed {
+			return &reportError{409, fmt.Errorf("report is %s", report.Status)}
+		}
+		report.Assignee = sql.NullString{String: assignee, Valid: true}
+		if report.Status == reportStatusOpen {
+			report.Status = reportStatusInReview
+		}
+		return recordModerationAction(r.Context(), qtx, moderator, "assign", *report, uuid.NullUUID{}, assignee)
+	})
+	writeReportResult(w, report, err)
+}
+
+func (cfg *apiConfig) handlerPostReportStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	type requestVals struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	report, err := cfg.updateReport(r.Context(), reportID, func(qtx *database.Queries, report *database.Report) error {
+		if !slices.Contains(reportTransitions[report.Status], params.Status) {
+			return &reportError{409, fmt.Errorf("cannot move report from %s to %s", report.Status, params.Status)}
+		}
+
+		report.Status = params.Status
+		switch params.Status {
+		case reportStatusOpen:
+			// Reopening starts over.
+			report.Assignee = sql.NullString{}
+			report.Resolution = sql.NullString{}
+			report.ResolvedAt = sql.NullTime{}
+		case reportStatusDismissed:
+			report.ResolvedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		}
+
+		return recordModerationAction(r.Context(), qtx, moderator, "status:"+params.Status, *report, uuid.NullUUID{}, params.Note)
+	})
+	writeReportResult(w, report, err)
+}
+
+func (cfg *apiConfig) handlerPostReportResolve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	type requestVals struct {
+		Action       string `json:"action"`
+		Note         string `json:"note"`
+		SuspendHours int    `json:"suspend_hours"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	if !slices.Contains(resolutionActions, params.Action) {
+		returnError(w, fmt.Errorf("action must be one of %s", strings.Join(resolutionActions, ", ")), 400)
+		return
+	}
+	suspension := time.Duration(params.SuspendHours) * time.Hour
+	if params.Action == resolutionSuspend && (suspension <= 0 || suspension > maxSuspension) {
+		returnError(w, fmt.Errorf("suspend_hours must be between 1 and %d", int(maxSuspension.Hours())), 400)
+		return
+	}
+
+	var author uuid.NullUUID
+	var hidden *database.Chirp
+	report, err := cfg.updateReport(r.Context(), reportID, func(qtx *database.Queries, report *database.Report) error {
+		if report.Status == reportStatusResolved || report.Status == reportStatusDismissed {
+			return &reportError{409, fmt.Errorf("report is already %s", report.Status)}
+		}
+
+		chirp, err := qtx.GetChirpIncludingDeleted(r.Context(), report.ChirpID)
+		if err != nil {
+			return err
+		}
+		author = uuid.NullUUID{UUID: chirp.UserID, Valid: true}
+		now := time.Now().UTC()
+
+		switch params.Action {
+		case resolutionHideChirp:
+			if err := qtx.HideChirp(r.Context(), chirp.ID); err != nil {
+				return err
+			}
+			if err := qtx.RotateVariantURLKeys(r.Context(), chirp.ID); err != nil {
+				return err
+			}
+			// Subscribers only get told the chirp is gone, not why.
+			if err := enqueueOutboxEvent(r.Context(), qtx, webhooks.EventChirpDeleted, chirp.UserID, streamChirpDeleted{ID: chirp.ID, UserID: chirp.UserID}); err != nil {
+				return err
+			}
+			hidden = &chirp
+		case resolutionSuspend:
+			suspendUserArgs := database.SuspendUserParams{
+				ID:             chirp.UserID,
+				SuspendedUntil: sql.NullTime{Time: now.Add(suspension), Valid: true},
+			}
+			if err := qtx.SuspendUser(r.Context(), suspendUserArgs); err != nil {
+				return err
+			}
+		}
+
+		report.Status = reportStatusResolved
+		report.Resolution = sql.NullString{String: params.Action, Valid: true}
+		report.ResolvedAt = sql.NullTime{Time: now, Valid: true}
+		if !report.Assignee.Valid {
+			report.Assignee = sql.NullString{String: moderator, Valid: true}
+		}
+
+		note := params.Note
+		if params.Action == resolutionSuspend {
+			note = strings.TrimSpace(fmt.Sprintf("%dh %s", params.SuspendHours, note))
+		}
+		return recordModerationAction(r.Context(), qtx, moderator, params.Action, *report, author, note)
+	})
+	if err == nil && hidden != nil {
+		cfg.publishEvent(chirpTopics(*hidden), eventChirpDeleted, streamChirpDeleted{ID: hidden.ID, UserID: hidden.UserID})
+	}
+	if err == nil && params.Action == resolutionWarn {
+		// Let the author know straight away; GET /api/users/me/warnings
+		// keeps the record.
+		cfg.publishEvent([]string{notificationTopic(author.UUID)}, eventWarning, streamWarning{
+			ChirpID: report.ChirpID,
+			Note:    params.Note,
+		})
+	}
+	writeReportResult(w, report, err)
+}
+
+// handlerGetWarnings lists the warnings moderators gave the user, newest
+// first.
+func (cfg *apiConfig) handlerGetWarnings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	warnings, err := cfg.db.GetWarningsForUser(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	responseData := []warningInfo{}
+	for _, warning := range warnings {
+		responseData = append(responseData, newWarningInfo(warning))
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerGetModerationActions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 500 {
+			returnError(w, fmt.Errorf("limit must be between 1 and 500"), 400)
+			return
+		}
+		limit = n
+	}
+
+	actions, err := cfg.db.GetRecentModerationActions(r.Context(), int32(limit))
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if actions == nil {
+		actions = []database.ModerationAction{}
+	}
+
+	data, err := json.Marshal(actions)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/entitlements"
+	"github.com/MagnusTrier/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+const (
+	maxScheduleAhead       = time.Hour * 24 * 365
+	scheduledPublishBatch  = 100
+	minScheduleLeadSeconds = 60
+	// A scheduled chirp that keeps erroring is marked failed after
+	// scheduledMaxAttempts, waiting scheduledRetryDelay longer each time.
+	scheduledMaxAttempts = 5
+	scheduledRetryDelay  = time.Minute
+)
+
+type scheduledChirpInfo struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+	PublishAt time.Time `json:"publish_at"`
+	Status    string    `json:"status"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+func newScheduledChirpInfo(s database.ScheduledChirp) scheduledChirpInfo {
+	return scheduledChirpInfo{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		Body:      s.Body,
+		UserID:    s.UserID,
+		PublishAt: s.PublishAt,
+		Status:    s.Status,
+		LastError: s.LastError,
+	}
+}
+
+func validatePublishAt(publishAt time.Time) error {
+	now := time.Now()
+	if publishAt.Before(now.Add(minScheduleLeadSeconds * time.Second)) {
+		return fmt.Errorf("publish_at must be at least %d seconds in the future", minScheduleLeadSeconds)
+	}
+	if publishAt.After(now.Add(maxScheduleAhead)) {
+		return fmt.Errorf("publish_at can be at most %v in the future", maxScheduleAhead)
+	}
+	return nil
+}
+
+// scheduleChirp finishes a POST /api/chirps that asked for publish_at. The
+// body has already been through checkChirpBody.
+func (cfg *apiConfig) scheduleChirp(w http.ResponseWriter, r *http.Request, userID uuid.UUID, body string, publishAt time.Time) {
+	createScheduledChirpArgs := database.CreateScheduledChirpParams{
+		UserID:    userID,
+		Body:      body,
+		PublishAt: publishAt.UTC(),
+	}
+	scheduled, err := cfg.db.CreateScheduledChirp(r.Context(), createScheduledChirpArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(newScheduledChirpInfo(scheduled))
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(202)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerGetScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeChirpsWrite)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	scheduled, err := cfg.db.GetScheduledChirpsForUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	responseData := []scheduledChirpInfo{}
+	for _, s := range scheduled {
+		responseData = append(responseData, newScheduledChirpInfo(s))
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+// lockOwnScheduledChirp locks the scheduled chirp in the path for userID. The
+// publisher holds the same lock while publishing, so once this returns the
+// chirp is either still queued or already gone.
+func lockOwnScheduledChirp(w http.ResponseWriter, r *http.Request, qtx *database.Queries, userID uuid.UUID) (database.ScheduledChirp, bool) {
+	scheduledID, err := uuid.Parse(r.PathValue("scheduledID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return database.ScheduledChirp{}, false
+	}
+
+	scheduled, err := qtx.LockScheduledChirp(r.Context(), scheduledID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && scheduled.UserID != userID) {
+		returnError(w, fmt.Errorf("scheduled chirp not found, it may already be published"), 404)
+		return database.ScheduledChirp{}, false
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return database.ScheduledChirp{}, false
+	}
+	return scheduled, true
+}
+
+func (cfg *apiConfig) handlerPatchScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeChirpsWrite)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	type requestVals struct {
+		Body      *string    `json:"body"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	params := requestVals{}
+	if err := decoder.Decode(&params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	plan, err := cfg.planFor(r.Context(), user)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	if !cfg.requireEntitlement(w, plan, entitlements.ScheduleChirps) {
+		return
+	}
+
+	if params.PublishAt != nil {
+		if err := validatePublishAt(*params.PublishAt); err != nil {
+			returnError(w, err, 400)
+			return
+		}
+	}
+
+	body := ""
+	if params.Body != nil {
+		moderated, ok := cfg.checkChirpBody(w, plan, *params.Body)
+		if !ok {
+			return
+		}
+		body = moderated.Text
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	scheduled, ok := lockOwnScheduledChirp(w, r, qtx, userID)
+	if !ok {
+		return
+	}
+
+	updateScheduledChirpArgs := database.UpdateScheduledChirpParams{
+		ID:        scheduled.ID,
+		Body:      scheduled.Body,
+		PublishAt: scheduled.PublishAt,
+	}
+	if params.Body != nil {
+		updateScheduledChirpArgs.Body = body
+	}
+	if params.PublishAt != nil {
+		updateScheduledChirpArgs.PublishAt = params.PublishAt.UTC()
+	}
+	scheduled, err = qtx.UpdateScheduledChirp(r.Context(), updateScheduledChirpArgs)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(newScheduledChirpInfo(scheduled))
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerDeleteScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeChirpsWrite)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	scheduled, ok := lockOwnScheduledChirp(w, r, qtx, userID)
+	if !ok {
+		return
+	}
+
+	if err := qtx.DeleteScheduledChirp(r.Context(), scheduled.ID); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// publishNextScheduledChirp publishes one due chirp and reports whether there
+// was one. Claiming with SKIP LOCKED and deleting the queue entry in the same
+// transaction as creating the chirp means each scheduled chirp is published
+// exactly once, however many instances are polling.
+func (cfg *apiConfig) publishNextScheduledChirp(ctx context.Context) (bool, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	scheduled, err := qtx.ClaimDueScheduledChirp(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := cfg.publishScheduledChirp(ctx, tx, qtx, scheduled); err != nil {
+		tx.Rollback()
+		return true, cfg.recordScheduledChirpError(ctx, scheduled, err)
+	}
+	return true, nil
+}
+
+// recordScheduledChirpError puts a scheduled chirp that couldn't be published
+// back for a later retry, so it doesn't hold up the chirps behind it, and
+// gives up on it after scheduledMaxAttempts.
+func (cfg *apiConfig) recordScheduledChirpError(ctx context.Context, scheduled database.ScheduledChirp, publishErr error) error {
+	delay := time.Duration(scheduled.Attempts+1) * scheduledRetryDelay
+	recordScheduledChirpErrorArgs := database.RecordScheduledChirpErrorParams{
+		ID:          scheduled.ID,
+		LastError:   publishErr.Error(),
+		RetryAt:     sql.NullTime{Time: time.Now().UTC().Add(delay), Valid: true},
+		MaxAttempts: scheduledMaxAttempts,
+	}
+	if _, err := cfg.db.RecordScheduledChirpError(ctx, recordScheduledChirpErrorArgs); err != nil {
+		return err
+	}
+	fmt.Printf("scheduled chirps: %s: %v\n", scheduled.ID, publishErr)
+	return nil
+}
+
+// publishScheduledChirp turns a claimed scheduled chirp into a chirp and
+// commits tx. Reasons the chirp can never be published mark it failed
+// instead; any other error leaves tx to be rolled back.
+func (cfg *apiConfig) publishScheduledChirp(ctx context.Context, tx *sql.Tx, qtx *database.Queries, scheduled database.ScheduledChirp) error {
+	fail := func(reason string) error {
+		failScheduledChirpArgs := database.FailScheduledChirpParams{
+			ID:        scheduled.ID,
+			LastError: reason,
+		}
+		if err := qtx.FailScheduledChirp(ctx, failScheduledChirpArgs); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	user, err := qtx.GetUser(ctx, scheduled.UserID)
+	if err != nil {
+		return err
+	}
+	if isSuspended(user) {
+		return fail("account is suspended")
+	}
+
+	// The rules may have changed since the chirp was scheduled.
+	moderated := cfg.moderator.Check(scheduled.Body)
+	if moderated.Rejected() {
+		return fail("rejected by moderation")
+	}
+
+	publishChirpArgs := database.PublishChirpParams{
+		ID:     scheduled.ID,
+		Body:   moderated.Text,
+		UserID: scheduled.UserID,
+	}
+	chirp, err := qtx.PublishChirp(ctx, publishChirpArgs)
+	if err != nil {
+		return err
+	}
+
+	if err := qtx.DeleteScheduledChirp(ctx, scheduled.ID); err != nil {
+		return err
+	}
+
+	if err := recordModerationFlags(ctx, qtx, chirp.ID, moderated); err != nil {
+		return err
+	}
+
+	if err := enqueueOutboxEvent(ctx, qtx, webhooks.EventChirpCreated, chirp.UserID, newChirpInfo(chirp)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	cfg.publishChirp(ctx, eventChirp, chirp)
+	return nil
+}
+
+func (cfg *apiConfig) runChirpPublisher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for i := 0; i < scheduledPublishBatch; i++ {
+			published, err := cfg.publishNextScheduledChirp(ctx)
+			if err != nil {
+				fmt.Printf("scheduled chirps: %v\n", err)
+				break
+			}
+			if !published {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,109 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (id, created_at, user_id, storage_key, content_type, size_bytes, width, height, alt_text)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7,
+	$8
+)
+RETURNING *;
+
+-- name: GetAttachment :one
+SELECT * FROM attachments
+WHERE id = $1;
+
+-- name: AttachToChirp :execrows
+UPDATE attachments
+SET chirp_id = sqlc.arg(chirp_id)
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+AND user_id = sqlc.arg(user_id)
+AND chirp_id IS NULL;
+
+-- name: GetAttachmentsForChirps :many
+SELECT * FROM attachments
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY created_at ASC;
+
+-- name: ClaimUnprocessedAttachment :one
+SELECT * FROM attachments
+WHERE processed_at IS NULL
+AND (retry_at IS NULL OR retry_at <= NOW())
+ORDER BY created_at ASC
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkAttachmentProcessed :exec
+UPDATE attachments
+SET blurhash = $2,
+	processed_at = NOW()
+WHERE id = $1;
+
+-- name: RecordAttachmentProcessingError :exec
+UPDATE attachments
+SET processing_attempts = processing_attempts + 1,
+	retry_at = $2
+WHERE id = $1;
+
+-- name: CreateAttachmentVariant :one
+INSERT INTO attachment_variants (attachment_id, name, storage_key, content_type, size_bytes, width, height)
+VALUES (
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6,
+	$7
+)
+ON CONFLICT (attachment_id, name) DO UPDATE
+SET storage_key = EXCLUDED.storage_key,
+	content_type = EXCLUDED.content_type,
+	size_bytes = EXCLUDED.size_bytes,
+	width = EXCLUDED.width,
+	height = EXCLUDED.height
+RETURNING *;
+
+-- name: GetAttachmentVariant :one
+SELECT * FROM attachment_variants
+WHERE attachment_id = $1
+AND name = $2;
+
+-- name: GetVariantsForAttachments :many
+SELECT * FROM attachment_variants
+WHERE attachment_id = ANY(sqlc.arg(attachment_ids)::uuid[])
+ORDER BY width ASC;
+
+-- name: RotateVariantURLKeys :exec
+UPDATE attachment_variants
+SET url_key = gen_random_uuid()
+WHERE attachment_id IN (
+	SELECT id FROM attachments
+	WHERE chirp_id = sqlc.arg(chirp_id)::uuid
+);
+
+-- name: GetAttachmentsOfPurgeableChirps :many
+SELECT attachments.* FROM attachments
+JOIN chirps ON chirps.id = attachments.chirp_id
+WHERE chirps.deleted_at < $1
+AND NOT EXISTS (
+	SELECT 1 FROM reports
+	WHERE reports.chirp_id = chirps.id
+	AND reports.status IN ('open', 'in_review')
+);
+
+-- name: ClaimStaleUnattachedAttachment :one
+SELECT * FROM attachments
+WHERE chirp_id IS NULL
+AND created_at < $1
+ORDER BY created_at ASC
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: DeleteAttachment :exec
+DELETE FROM attachments
+WHERE id = $1;
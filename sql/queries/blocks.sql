@@ -0,0 +1,35 @@
+-- name: CreateBlock :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (
+	$1,
+	$2,
+	NOW()
+)
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteBlock :exec
+DELETE FROM blocks
+WHERE blocker_id = $1
+AND blocked_id = $2;
+
+-- name: GetBlocksForUser :many
+SELECT * FROM blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: HasBlockBetween :one
+SELECT EXISTS (
+	SELECT 1 FROM blocks
+	WHERE (blocker_id = $1 AND blocked_id = $2)
+	OR (blocker_id = $2 AND blocked_id = $1)
+);
+
+-- name: GetFilteredAuthorsForUser :many
+SELECT blocked_id AS user_id FROM blocks
+WHERE blocks.blocker_id = sqlc.arg(user_id)
+UNION
+SELECT blocker_id FROM blocks
+WHERE blocks.blocked_id = sqlc.arg(user_id)
+UNION
+SELECT muted_id FROM mutes
+WHERE mutes.muter_id = sqlc.arg(user_id);
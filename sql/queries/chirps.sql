@@ -11,18 +11,81 @@ RETURNING *;
 
 -- name: GetAllChirps :many
 SELECT * FROM chirps
+WHERE hidden_at IS NULL
+AND deleted_at IS NULL
 ORDER BY created_at ASC;
 
 -- name: GetAllChirpsFromUser :many
 SELECT * FROM chirps
 WHERE user_id = $1
+AND hidden_at IS NULL
+AND deleted_at IS NULL
 ORDER BY created_at ASC;
 
 
 -- name: GetChirp :one
 SELECT * FROM chirps
+WHERE id = $1
+AND deleted_at IS NULL;
+
+-- name: GetChirpIncludingDeleted :one
+SELECT * FROM chirps
+WHERE id = $1;
+
+-- name: SoftDeleteChirp :exec
+UPDATE chirps
+SET deleted_at = NOW()
 WHERE id = $1;
 
--- name: DeleteChirp :exec
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = $1
+AND user_id = $2
+AND deleted_at > $3
+RETURNING *;
+
+-- name: GetDeletedChirpsForUser :many
+SELECT * FROM chirps
+WHERE user_id = $1
+AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
+-- name: PurgeDeletedChirps :execrows
 DELETE FROM chirps
+WHERE deleted_at < $1
+AND NOT EXISTS (
+	SELECT 1 FROM reports
+	WHERE reports.chirp_id = chirps.id
+	AND reports.status IN ('open', 'in_review')
+);
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW()
 WHERE id = $1;
+
+-- name: LockChirp :one
+SELECT * FROM chirps
+WHERE id = $1
+AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2,
+	edited_at = NOW(),
+	updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: PublishChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+VALUES (
+	$1,
+	NOW(),
+	NOW(),
+	$2,
+	$3
+)
+RETURNING *;
@@ -0,0 +1,82 @@
+-- name: CreateCollection :one
+INSERT INTO collections (id, created_at, updated_at, user_id, name, description, is_private)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4
+)
+ON CONFLICT (user_id, name) DO NOTHING
+RETURNING *;
+
+-- name: CountCollectionsForUser :one
+SELECT COUNT(*) FROM collections
+WHERE user_id = $1;
+
+-- name: GetCollection :one
+SELECT * FROM collections
+WHERE id = $1;
+
+-- name: GetCollectionsForUser :many
+SELECT * FROM collections
+WHERE user_id = $1
+ORDER BY name ASC;
+
+-- name: UpdateCollection :one
+UPDATE collections
+SET name = $2,
+	description = $3,
+	is_private = $4,
+	updated_at = NOW()
+WHERE id = $1
+AND NOT EXISTS (
+	SELECT 1 FROM collections AS other
+	WHERE other.user_id = collections.user_id
+	AND other.name = $2
+	AND other.id <> $1
+)
+RETURNING *;
+
+-- name: DeleteCollection :exec
+DELETE FROM collections
+WHERE id = $1;
+
+-- name: AddChirpToCollection :exec
+INSERT INTO collection_chirps (collection_id, chirp_id, created_at)
+VALUES (
+	$1,
+	$2,
+	NOW()
+)
+ON CONFLICT (collection_id, chirp_id) DO NOTHING;
+
+-- name: RemoveChirpFromCollection :execrows
+DELETE FROM collection_chirps
+WHERE collection_id = $1
+AND chirp_id = $2;
+
+-- name: RemoveChirpFromUserCollections :exec
+DELETE FROM collection_chirps
+USING collections
+WHERE collections.id = collection_chirps.collection_id
+AND collections.user_id = $1
+AND collection_chirps.chirp_id = $2;
+
+-- name: GetCollectionChirps :many
+SELECT chirps.*, collection_chirps.created_at AS saved_at
+FROM collection_chirps
+JOIN chirps ON chirps.id = collection_chirps.chirp_id
+WHERE collection_chirps.collection_id = sqlc.arg(collection_id)
+AND chirps.deleted_at IS NULL
+AND chirps.hidden_at IS NULL
+AND NOT EXISTS (
+	SELECT 1 FROM blocks
+	WHERE (blocks.blocker_id = sqlc.arg(viewer_id) AND blocks.blocked_id = chirps.user_id)
+	OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id))
+)
+AND (collection_chirps.created_at, collection_chirps.chirp_id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY collection_chirps.created_at DESC, collection_chirps.chirp_id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,104 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by, is_group, direct_key)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3
+)
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+
+-- name: GetConversationByDirectKey :one
+SELECT * FROM conversations
+WHERE direct_key = $1;
+
+-- name: GetConversation :one
+SELECT * FROM conversations
+WHERE id = $1;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES (
+	$1,
+	$2,
+	NOW()
+)
+ON CONFLICT (conversation_id, user_id) DO NOTHING;
+
+-- name: GetConversationMembers :many
+SELECT * FROM conversation_members
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY joined_at ASC, user_id ASC;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = GREATEST(COALESCE(last_read_at, sqlc.arg(read_at)::timestamp), sqlc.arg(read_at)::timestamp)
+WHERE conversation_id = sqlc.arg(conversation_id)
+AND user_id = sqlc.arg(user_id);
+
+-- name: GetConversationsForUser :many
+SELECT conversations.*, (
+	SELECT COUNT(*) FROM direct_messages
+	WHERE direct_messages.conversation_id = conversations.id
+	AND direct_messages.sender_id <> sqlc.arg(user_id)
+	AND direct_messages.created_at > COALESCE(conversation_members.last_read_at, '-infinity'::timestamp)
+	AND NOT EXISTS (
+		SELECT 1 FROM blocks
+		WHERE (blocks.blocker_id = sqlc.arg(user_id) AND blocks.blocked_id = direct_messages.sender_id)
+		OR (blocks.blocker_id = direct_messages.sender_id AND blocks.blocked_id = sqlc.arg(user_id))
+	)
+) AS unread_count
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = sqlc.arg(user_id)
+AND (conversations.updated_at, conversations.id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY conversations.updated_at DESC, conversations.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: CreateDirectMessage :one
+INSERT INTO direct_messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3
+)
+RETURNING *;
+
+-- name: GetDirectMessage :one
+SELECT * FROM direct_messages
+WHERE id = $1;
+
+-- name: GetDirectMessages :many
+SELECT * FROM direct_messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+AND NOT EXISTS (
+	SELECT 1 FROM blocks
+	WHERE (blocks.blocker_id = sqlc.arg(viewer_id) AND blocks.blocked_id = direct_messages.sender_id)
+	OR (blocks.blocker_id = direct_messages.sender_id AND blocks.blocked_id = sqlc.arg(viewer_id))
+)
+AND (created_at, id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: CountUnreadDirectMessages :one
+SELECT COUNT(*) FROM direct_messages
+JOIN conversation_members ON conversation_members.conversation_id = direct_messages.conversation_id
+AND conversation_members.user_id = sqlc.arg(user_id)
+WHERE direct_messages.conversation_id = sqlc.arg(conversation_id)
+AND direct_messages.sender_id <> sqlc.arg(user_id)
+AND direct_messages.created_at > COALESCE(conversation_members.last_read_at, '-infinity'::timestamp)
+AND NOT EXISTS (
+	SELECT 1 FROM blocks
+	WHERE (blocks.blocker_id = sqlc.arg(user_id) AND blocks.blocked_id = direct_messages.sender_id)
+	OR (blocks.blocker_id = direct_messages.sender_id AND blocks.blocked_id = sqlc.arg(user_id))
+);
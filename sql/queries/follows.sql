@@ -0,0 +1,35 @@
+-- name: CreateFollow :execrows
+INSERT INTO follows (follower_id, followed_id, created_at)
+VALUES (
+	$1,
+	$2,
+	NOW()
+)
+ON CONFLICT (follower_id, followed_id) DO NOTHING;
+
+-- name: DeleteFollow :exec
+DELETE FROM follows
+WHERE follower_id = $1
+AND followed_id = $2;
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = $1 AND followed_id = $2)
+OR (follower_id = $2 AND followed_id = $1);
+
+-- name: IsFollowing :one
+SELECT EXISTS (
+	SELECT 1 FROM follows
+	WHERE follower_id = $1
+	AND followed_id = $2
+);
+
+-- name: GetFollowing :many
+SELECT * FROM follows
+WHERE follower_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetFollowers :many
+SELECT * FROM follows
+WHERE followed_id = $1
+ORDER BY created_at DESC;
@@ -0,0 +1,111 @@
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, user_id, name, description, is_private)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4
+)
+ON CONFLICT (user_id, name) DO NOTHING
+RETURNING *;
+
+-- name: CountListsForUser :one
+SELECT COUNT(*) FROM lists
+WHERE user_id = $1;
+
+-- name: GetList :one
+SELECT * FROM lists
+WHERE id = $1;
+
+-- name: GetListsForUser :many
+SELECT * FROM lists
+WHERE user_id = $1
+ORDER BY name ASC;
+
+-- name: GetSubscribedLists :many
+SELECT lists.* FROM lists
+JOIN list_subscriptions ON list_subscriptions.list_id = lists.id
+WHERE list_subscriptions.user_id = $1
+AND lists.is_private = FALSE
+ORDER BY list_subscriptions.created_at DESC;
+
+-- name: UpdateList :one
+UPDATE lists
+SET name = $2,
+	description = $3,
+	is_private = $4,
+	updated_at = NOW()
+WHERE id = $1
+AND NOT EXISTS (
+	SELECT 1 FROM lists AS other
+	WHERE other.user_id = lists.user_id
+	AND other.name = $2
+	AND other.id <> $1
+)
+RETURNING *;
+
+-- name: DeleteList :exec
+DELETE FROM lists
+WHERE id = $1;
+
+-- name: CountListMembers :one
+SELECT COUNT(*) FROM list_members
+WHERE list_id = $1;
+
+-- name: AddListMember :execrows
+INSERT INTO list_members (list_id, user_id, created_at)
+VALUES (
+	$1,
+	$2,
+	NOW()
+)
+ON CONFLICT (list_id, user_id) DO NOTHING;
+
+-- name: RemoveListMember :execrows
+DELETE FROM list_members
+WHERE list_id = $1
+AND user_id = $2;
+
+-- name: GetListMembers :many
+SELECT * FROM list_members
+WHERE list_id = sqlc.arg(list_id)
+AND (created_at, user_id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY created_at DESC, user_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: CreateListSubscription :exec
+INSERT INTO list_subscriptions (list_id, user_id, created_at)
+VALUES (
+	$1,
+	$2,
+	NOW()
+)
+ON CONFLICT (list_id, user_id) DO NOTHING;
+
+-- name: DeleteListSubscription :execrows
+DELETE FROM list_subscriptions
+WHERE list_id = $1
+AND user_id = $2;
+
+-- name: GetListTimeline :many
+SELECT chirps.* FROM chirps
+JOIN list_members ON list_members.user_id = chirps.user_id
+WHERE list_members.list_id = sqlc.arg(list_id)
+AND chirps.deleted_at IS NULL
+AND chirps.hidden_at IS NULL
+AND NOT EXISTS (
+	SELECT 1 FROM blocks
+	WHERE (blocks.blocker_id = sqlc.arg(viewer_id) AND blocks.blocked_id = chirps.user_id)
+	OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id))
+)
+AND NOT EXISTS (
+	SELECT 1 FROM mutes
+	WHERE mutes.muter_id = sqlc.arg(viewer_id)
+	AND mutes.muted_id = chirps.user_id
+)
+AND (chirps.created_at, chirps.id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
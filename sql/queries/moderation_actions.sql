@@ -0,0 +1,28 @@
+-- name: CreateModerationAction :exec
+INSERT INTO moderation_actions (id, created_at, moderator, action, report_id, chirp_id, user_id, note)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6
+);
+
+-- name: GetModerationActionsForReport :many
+SELECT * FROM moderation_actions
+WHERE report_id = $1
+ORDER BY created_at ASC;
+
+-- name: GetRecentModerationActions :many
+SELECT * FROM moderation_actions
+ORDER BY created_at DESC
+LIMIT $1;
+
+-- name: GetWarningsForUser :many
+SELECT * FROM moderation_actions
+WHERE user_id = $1
+AND action = 'warn'
+ORDER BY created_at DESC;
@@ -0,0 +1,14 @@
+-- name: CreateModerationFlag :exec
+INSERT INTO moderation_flags (id, created_at, chirp_id, rule, match)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3
+);
+
+-- name: GetRecentModerationFlags :many
+SELECT * FROM moderation_flags
+ORDER BY created_at DESC
+LIMIT $1;
@@ -0,0 +1,73 @@
+-- name: CreateNotification :execrows
+INSERT INTO notifications (id, created_at, updated_at, user_id, type, chirp_id, group_key, actor_ids)
+SELECT
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	sqlc.arg(user_id)::uuid,
+	sqlc.arg(type)::text,
+	sqlc.narg(chirp_id)::uuid,
+	sqlc.arg(group_key)::text,
+	ARRAY[sqlc.arg(actor_id)::uuid]
+WHERE sqlc.arg(user_id)::uuid <> sqlc.arg(actor_id)::uuid
+AND NOT EXISTS (
+	SELECT 1 FROM notification_preferences
+	WHERE notification_preferences.user_id = sqlc.arg(user_id)
+	AND notification_preferences.type = sqlc.arg(type)
+	AND NOT notification_preferences.enabled
+)
+AND NOT EXISTS (
+	SELECT 1 FROM blocks
+	WHERE (blocks.blocker_id = sqlc.arg(user_id) AND blocks.blocked_id = sqlc.arg(actor_id)::uuid)
+	OR (blocks.blocker_id = sqlc.arg(actor_id)::uuid AND blocks.blocked_id = sqlc.arg(user_id))
+)
+AND NOT EXISTS (
+	SELECT 1 FROM mutes
+	WHERE mutes.muter_id = sqlc.arg(user_id)
+	AND mutes.muted_id = sqlc.arg(actor_id)::uuid
+)
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL DO UPDATE
+SET updated_at = NOW(),
+actor_ids = CASE
+	WHEN EXCLUDED.actor_ids <@ notifications.actor_ids THEN notifications.actor_ids
+	ELSE EXCLUDED.actor_ids || notifications.actor_ids
+END;
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+AND (NOT sqlc.arg(unread_only)::boolean OR read_at IS NULL)
+AND (created_at, id) < (sqlc.arg(before_time)::timestamp, sqlc.arg(before_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1
+AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1
+AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = $1;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled)
+VALUES (
+	$1,
+	$2,
+	$3
+)
+ON CONFLICT (user_id, type) DO UPDATE
+SET enabled = EXCLUDED.enabled;
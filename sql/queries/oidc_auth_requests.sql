@@ -0,0 +1,19 @@
+-- name: CreateOidcAuthRequest :exec
+INSERT INTO oidc_auth_requests (state, created_at, provider, nonce, code_verifier, expires_at)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	$4,
+	$5
+);
+
+-- name: ConsumeOidcAuthRequest :one
+DELETE FROM oidc_auth_requests
+WHERE state = $1
+RETURNING *;
+
+-- name: DeleteExpiredOidcAuthRequests :exec
+DELETE FROM oidc_auth_requests
+WHERE expires_at < NOW();
@@ -0,0 +1,62 @@
+-- name: CreatePoll :one
+INSERT INTO polls (chirp_id, created_at, closes_at, multiple_choice, results_visibility)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3,
+	$4
+)
+RETURNING *;
+
+-- name: CreatePollOption :one
+INSERT INTO poll_options (id, chirp_id, position, text)
+VALUES (
+	gen_random_uuid(),
+	$1,
+	$2,
+	$3
+)
+RETURNING *;
+
+-- name: GetPoll :one
+SELECT * FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetPollOptions :many
+SELECT * FROM poll_options
+WHERE chirp_id = $1
+ORDER BY position ASC;
+
+-- name: GetPollsForChirps :many
+SELECT polls.*, (
+	SELECT COUNT(*) FROM poll_votes
+	WHERE poll_votes.chirp_id = polls.chirp_id
+) AS voters
+FROM polls
+WHERE polls.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetPollOptionsForChirps :many
+SELECT poll_options.*, (
+	SELECT COUNT(*) FROM poll_votes
+	WHERE poll_votes.chirp_id = poll_options.chirp_id
+	AND poll_options.id = ANY(poll_votes.option_ids)
+) AS votes
+FROM poll_options
+WHERE poll_options.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY poll_options.chirp_id, poll_options.position ASC;
+
+-- name: GetPollVotesForUser :many
+SELECT * FROM poll_votes
+WHERE user_id = sqlc.arg(user_id)
+AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: CreatePollVote :execrows
+INSERT INTO poll_votes (chirp_id, user_id, option_ids, created_at)
+VALUES (
+	$1,
+	$2,
+	$3,
+	NOW()
+)
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
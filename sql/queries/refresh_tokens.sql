@@ -9,6 +9,19 @@ VALUES (
 )
 RETURNING *;
 
+-- name: CreateClientRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, client_id, scope)
+VALUES (
+	$1,
+	NOW(),
+	NOW(),
+	$2,
+	$3,
+	$4,
+	$5
+)
+RETURNING *;
+
 -- name: GetToken :one
 SELECT *, 
 	(NOW() > expires_at) as expired
@@ -21,3 +34,12 @@ UPDATE refresh_tokens
 SET revoked_at = NOW(),
 	updated_at = NOW()
 WHERE token = $1;
+
+-- name: ConsumeRefreshToken :one
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+	updated_at = NOW()
+WHERE token = $1
+AND revoked_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
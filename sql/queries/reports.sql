@@ -0,0 +1,43 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, chirp_id, reporter_id, reason, details)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4
+)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: LockReport :one
+SELECT * FROM reports
+WHERE id = $1
+FOR UPDATE;
+
+-- name: GetRecentReports :many
+SELECT * FROM reports
+ORDER BY created_at DESC
+LIMIT $1;
+
+-- name: GetReportsByStatus :many
+SELECT * FROM reports
+WHERE status = $1
+ORDER BY created_at ASC
+LIMIT $2;
+
+-- name: UpdateReport :one
+UPDATE reports
+SET status = $2,
+	assignee = $3,
+	resolution = $4,
+	resolved_at = $5,
+	updated_at = NOW()
+WHERE id = $1
+RETURNING *;
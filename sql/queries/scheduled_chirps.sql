@@ -0,0 +1,63 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, user_id, body, publish_at)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3
+)
+RETURNING *;
+
+-- name: GetScheduledChirpsForUser :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at ASC;
+
+-- name: LockScheduledChirp :one
+SELECT * FROM scheduled_chirps
+WHERE id = $1
+FOR UPDATE;
+
+-- name: UpdateScheduledChirp :one
+UPDATE scheduled_chirps
+SET body = $2,
+	publish_at = $3,
+	status = 'scheduled',
+	last_error = '',
+	attempts = 0,
+	retry_at = NULL,
+	updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteScheduledChirp :exec
+DELETE FROM scheduled_chirps
+WHERE id = $1;
+
+-- name: ClaimDueScheduledChirp :one
+SELECT * FROM scheduled_chirps
+WHERE status = 'scheduled'
+AND publish_at <= NOW()
+AND (retry_at IS NULL OR retry_at <= NOW())
+ORDER BY publish_at ASC
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: FailScheduledChirp :exec
+UPDATE scheduled_chirps
+SET status = 'failed',
+	last_error = $2,
+	updated_at = NOW()
+WHERE id = $1;
+
+-- name: RecordScheduledChirpError :one
+UPDATE scheduled_chirps
+SET attempts = attempts + 1,
+	last_error = sqlc.arg(last_error),
+	retry_at = sqlc.arg(retry_at),
+	status = CASE WHEN attempts + 1 >= sqlc.arg(max_attempts)::integer THEN 'failed' ELSE status END,
+	updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
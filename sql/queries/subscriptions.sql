@@ -0,0 +1,61 @@
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end, cancel_at_period_end, canceled_at)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+	status = EXCLUDED.status,
+	current_period_end = EXCLUDED.current_period_end,
+	cancel_at_period_end = EXCLUDED.cancel_at_period_end,
+	canceled_at = EXCLUDED.canceled_at,
+	updated_at = NOW()
+RETURNING *;
+
+-- name: GetSubscriptionForUser :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: MarkOverdueSubscriptionsPastDue :many
+UPDATE subscriptions
+SET status = 'past_due',
+	updated_at = NOW()
+WHERE status = 'active'
+AND NOT cancel_at_period_end
+AND current_period_end < NOW()
+RETURNING *;
+
+-- name: CancelLapsedSubscriptions :many
+UPDATE subscriptions
+SET status = 'canceled',
+	canceled_at = NOW(),
+	updated_at = NOW()
+WHERE (status = 'active' AND cancel_at_period_end AND current_period_end < NOW())
+OR (status = 'past_due' AND current_period_end < sqlc.arg(grace_cutoff))
+RETURNING *;
+
+-- name: CreateSubscriptionHistory :exec
+INSERT INTO subscription_history (id, created_at, subscription_id, event, plan, status, current_period_end, webhook_event_id)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4,
+	$5,
+	$6
+);
+
+-- name: GetSubscriptionHistory :many
+SELECT * FROM subscription_history
+WHERE subscription_id = $1
+ORDER BY created_at DESC;
@@ -28,8 +28,41 @@ SET email = $2,
 WHERE id = $1
 RETURNING *;
 
--- name: SetUserIsChirpyRed :exec
+-- name: SyncUserIsChirpyRed :exec
 UPDATE users
-SET is_chirpy_red = $2,
+SET is_chirpy_red = EXISTS (
+		SELECT 1 FROM subscriptions
+		WHERE subscriptions.user_id = users.id
+		AND subscriptions.status IN ('active', 'past_due')
+	),
 	updated_at = NOW()
 WHERE id = $1;
+
+-- name: SetUserHashedPassword :exec
+UPDATE users
+SET hashed_password = $2,
+	updated_at = NOW()
+WHERE id = $1;
+
+-- name: CreateUserWithoutPassword :one
+INSERT INTO users (id, created_at, updated_at, email)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1
+)
+RETURNING *;
+
+-- name: SuspendUser :exec
+UPDATE users
+SET suspended_until = $2,
+	updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserDMPolicy :one
+UPDATE users
+SET dm_policy = $2,
+	updated_at = NOW()
+WHERE id = $1
+RETURNING *;
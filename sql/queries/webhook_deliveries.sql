@@ -0,0 +1,53 @@
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, outbox_id, next_attempt_at)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	NOW()
+);
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET locked_until = sqlc.arg(locked_until),
+	updated_at = NOW()
+WHERE id IN (
+	SELECT id FROM webhook_deliveries
+	WHERE status = 'pending'
+	AND next_attempt_at <= NOW()
+	AND (locked_until IS NULL OR locked_until < NOW())
+	ORDER BY next_attempt_at ASC
+	LIMIT sqlc.arg(batch_size)
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded',
+	attempts = attempts + 1,
+	last_status_code = $2,
+	last_error = '',
+	locked_until = NULL,
+	delivered_at = NOW(),
+	updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = $2,
+	attempts = attempts + 1,
+	next_attempt_at = $3,
+	last_status_code = $4,
+	last_error = $5,
+	locked_until = NULL,
+	updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookDeliveriesForEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
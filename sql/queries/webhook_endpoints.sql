@@ -0,0 +1,36 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, events)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4
+)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: GetWebhookEndpointsForUser :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: GetActiveWebhookEndpointsForEvent :many
+SELECT * FROM webhook_endpoints
+WHERE active
+AND (cardinality(events) = 0 OR sqlc.arg(event_type)::text = ANY(events))
+AND NOT EXISTS (
+	SELECT 1 FROM blocks
+	WHERE (blocks.blocker_id = webhook_endpoints.user_id AND blocks.blocked_id = sqlc.narg(author_id)::uuid)
+	OR (blocks.blocker_id = sqlc.narg(author_id)::uuid AND blocks.blocked_id = webhook_endpoints.user_id)
+);
+
+-- name: DeleteWebhookEndpoint :exec
+DELETE FROM webhook_endpoints
+WHERE id = $1
+AND user_id = $2;
@@ -0,0 +1,58 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, created_at, updated_at, event_type, user_id, payload, occurred_at)
+VALUES (
+	$1,
+	NOW(),
+	NOW(),
+	$2,
+	$3,
+	$4,
+	$5
+)
+ON CONFLICT (id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: LockWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1
+FOR UPDATE;
+
+-- name: GetRecentWebhookEvents :many
+SELECT * FROM webhook_events
+ORDER BY created_at DESC
+LIMIT $1;
+
+-- name: GetWebhookEventsByStatus :many
+SELECT * FROM webhook_events
+WHERE status = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: HasNewerProcessedWebhookEvent :one
+SELECT EXISTS (
+	SELECT 1 FROM webhook_events
+	WHERE user_id = $1
+	AND id <> $2
+	AND status = 'processed'
+	AND occurred_at > $3
+);
+
+-- name: SetWebhookEventStatus :exec
+UPDATE webhook_events
+SET status = $2,
+	last_error = $3,
+	attempts = attempts + 1,
+	last_attempt_at = NOW(),
+	updated_at = NOW()
+WHERE id = $1;
+
+-- name: ResetWebhookEvent :exec
+UPDATE webhook_events
+SET status = 'pending',
+	last_error = '',
+	updated_at = NOW()
+WHERE id = $1;
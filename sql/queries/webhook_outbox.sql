@@ -0,0 +1,26 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO webhook_outbox (id, created_at, event_type, payload, author_id)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3
+)
+RETURNING *;
+
+-- name: GetOutboxEvent :one
+SELECT * FROM webhook_outbox
+WHERE id = $1;
+
+-- name: ClaimUndispatchedOutboxEvents :many
+SELECT * FROM webhook_outbox
+WHERE dispatched_at IS NULL
+ORDER BY created_at ASC
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxEventDispatched :exec
+UPDATE webhook_outbox
+SET dispatched_at = NOW()
+WHERE id = $1;
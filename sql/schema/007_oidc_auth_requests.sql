@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE oidc_auth_requests (
+	state TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	provider TEXT NOT NULL,
+	nonce TEXT NOT NULL,
+	code_verifier TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oidc_auth_requests;
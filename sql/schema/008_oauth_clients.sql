@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE oauth_clients (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	secret_hash TEXT NOT NULL DEFAULT '',
+	redirect_uris TEXT[] NOT NULL,
+	scopes TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oauth_clients;
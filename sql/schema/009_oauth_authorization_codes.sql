@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE oauth_authorization_codes (
+	code_hash TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	client_id TEXT NOT NULL,
+	CONSTRAINT fk_client_id
+	FOREIGN KEY (client_id)
+	REFERENCES oauth_clients(id)
+	ON DELETE CASCADE,
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	redirect_uri TEXT NOT NULL,
+	scope TEXT NOT NULL,
+	code_challenge TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oauth_authorization_codes;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	event_type TEXT NOT NULL,
+	user_id UUID DEFAULT NULL,
+	payload JSONB NOT NULL,
+	occurred_at TIMESTAMP NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	last_attempt_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX webhook_events_user_id_idx ON webhook_events (user_id, occurred_at);
+CREATE INDEX webhook_events_status_idx ON webhook_events (status, created_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,45 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL UNIQUE,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	plan TEXT NOT NULL,
+	status TEXT NOT NULL,
+	current_period_end TIMESTAMP NOT NULL,
+	cancel_at_period_end BOOLEAN NOT NULL DEFAULT false,
+	canceled_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE TABLE subscription_history (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	subscription_id UUID NOT NULL,
+	CONSTRAINT fk_subscription_id
+	FOREIGN KEY (subscription_id)
+	REFERENCES subscriptions(id)
+	ON DELETE CASCADE,
+	event TEXT NOT NULL,
+	plan TEXT NOT NULL,
+	status TEXT NOT NULL,
+	current_period_end TIMESTAMP NOT NULL,
+	webhook_event_id TEXT DEFAULT NULL
+);
+
+-- Upgrades used to be permanent, so existing Chirpy Red users keep theirs.
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'red', 'active', '9999-12-31'
+FROM users
+WHERE is_chirpy_red;
+
+INSERT INTO subscription_history (id, created_at, subscription_id, event, plan, status, current_period_end)
+SELECT gen_random_uuid(), NOW(), id, 'migrated', plan, status, current_period_end
+FROM subscriptions;
+
+-- +goose Down
+DROP TABLE subscription_history;
+DROP TABLE subscriptions;
@@ -0,0 +1,57 @@
+-- +goose Up
+CREATE TABLE webhook_endpoints (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT[] NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT true
+);
+
+CREATE TABLE webhook_outbox (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	event_type TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	dispatched_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX webhook_outbox_pending_idx ON webhook_outbox (created_at)
+WHERE dispatched_at IS NULL;
+
+CREATE TABLE webhook_deliveries (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	endpoint_id UUID NOT NULL,
+	CONSTRAINT fk_endpoint_id
+	FOREIGN KEY (endpoint_id)
+	REFERENCES webhook_endpoints(id)
+	ON DELETE CASCADE,
+	outbox_id UUID NOT NULL,
+	CONSTRAINT fk_outbox_id
+	FOREIGN KEY (outbox_id)
+	REFERENCES webhook_outbox(id)
+	ON DELETE CASCADE,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP DEFAULT NULL,
+	last_status_code INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	delivered_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_outbox;
+DROP TABLE webhook_endpoints;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE moderation_flags (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	chirp_id UUID NOT NULL,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE,
+	rule TEXT NOT NULL,
+	match TEXT NOT NULL
+);
+
+CREATE INDEX moderation_flags_created_at_idx ON moderation_flags (created_at);
+
+-- +goose Down
+DROP TABLE moderation_flags;
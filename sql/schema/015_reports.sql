@@ -0,0 +1,50 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP DEFAULT NULL;
+ALTER TABLE chirps ADD COLUMN hidden_at TIMESTAMP DEFAULT NULL;
+
+CREATE TABLE reports (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	chirp_id UUID NOT NULL,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE,
+	reporter_id UUID NOT NULL,
+	CONSTRAINT fk_reporter_id
+	FOREIGN KEY (reporter_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	reason TEXT NOT NULL,
+	details TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL DEFAULT 'open',
+	assignee TEXT DEFAULT NULL,
+	resolution TEXT DEFAULT NULL,
+	resolved_at TIMESTAMP DEFAULT NULL,
+	UNIQUE (chirp_id, reporter_id)
+);
+
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+
+-- The audit trail deliberately has no foreign keys so it outlives the
+-- chirps, users and reports it talks about.
+CREATE TABLE moderation_actions (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	moderator TEXT NOT NULL,
+	action TEXT NOT NULL,
+	report_id UUID DEFAULT NULL,
+	chirp_id UUID DEFAULT NULL,
+	user_id UUID DEFAULT NULL,
+	note TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX moderation_actions_report_id_idx ON moderation_actions (report_id, created_at);
+CREATE INDEX moderation_actions_created_at_idx ON moderation_actions (created_at);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+ALTER TABLE chirps DROP COLUMN hidden_at;
+ALTER TABLE users DROP COLUMN suspended_until;
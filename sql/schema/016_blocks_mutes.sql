@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE blocks (
+	blocker_id UUID NOT NULL,
+	CONSTRAINT fk_blocker_id
+	FOREIGN KEY (blocker_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	blocked_id UUID NOT NULL,
+	CONSTRAINT fk_blocked_id
+	FOREIGN KEY (blocked_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX blocks_blocked_id_idx ON blocks (blocked_id);
+
+CREATE TABLE mutes (
+	muter_id UUID NOT NULL,
+	CONSTRAINT fk_muter_id
+	FOREIGN KEY (muter_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	muted_id UUID NOT NULL,
+	CONSTRAINT fk_muted_id
+	FOREIGN KEY (muted_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (muter_id, muted_id)
+);
+
+-- +goose Down
+DROP TABLE mutes;
+DROP TABLE blocks;
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE attachments (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	chirp_id UUID DEFAULT NULL,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE,
+	storage_key TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size_bytes BIGINT NOT NULL,
+	width INTEGER NOT NULL,
+	height INTEGER NOT NULL,
+	alt_text TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX attachments_chirp_id_idx ON attachments (chirp_id);
+
+-- +goose Down
+DROP TABLE attachments;
@@ -0,0 +1,28 @@
+-- +goose Up
+ALTER TABLE attachments
+ADD COLUMN blurhash TEXT DEFAULT NULL,
+ADD COLUMN processed_at TIMESTAMP DEFAULT NULL;
+
+CREATE INDEX attachments_unprocessed_idx ON attachments (created_at) WHERE processed_at IS NULL;
+
+CREATE TABLE attachment_variants (
+	attachment_id UUID NOT NULL,
+	CONSTRAINT fk_attachment_id
+	FOREIGN KEY (attachment_id)
+	REFERENCES attachments(id)
+	ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	storage_key TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size_bytes BIGINT NOT NULL,
+	width INTEGER NOT NULL,
+	height INTEGER NOT NULL,
+	PRIMARY KEY (attachment_id, name)
+);
+
+-- +goose Down
+DROP TABLE attachment_variants;
+
+ALTER TABLE attachments
+DROP COLUMN processed_at,
+DROP COLUMN blurhash;
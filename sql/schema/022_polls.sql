@@ -0,0 +1,47 @@
+-- +goose Up
+CREATE TABLE polls (
+	chirp_id UUID PRIMARY KEY,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	closes_at TIMESTAMP NOT NULL,
+	multiple_choice BOOLEAN NOT NULL DEFAULT FALSE,
+	results_visibility TEXT NOT NULL DEFAULT 'always'
+);
+
+CREATE TABLE poll_options (
+	id UUID PRIMARY KEY,
+	chirp_id UUID NOT NULL,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES polls(chirp_id)
+	ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	text TEXT NOT NULL,
+	UNIQUE (chirp_id, position)
+);
+
+-- One row per voter makes "one vote per user" a primary key, even when a
+-- multiple choice ballot picks several options.
+CREATE TABLE poll_votes (
+	chirp_id UUID NOT NULL,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES polls(chirp_id)
+	ON DELETE CASCADE,
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	option_ids UUID[] NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (chirp_id, user_id)
+);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
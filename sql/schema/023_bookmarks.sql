@@ -0,0 +1,54 @@
+-- +goose Up
+CREATE TABLE bookmarks (
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	chirp_id UUID NOT NULL,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX bookmarks_saved_idx ON bookmarks (user_id, created_at DESC, chirp_id DESC);
+
+CREATE TABLE collections (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	is_private BOOLEAN NOT NULL DEFAULT TRUE,
+	UNIQUE (user_id, name)
+);
+
+CREATE TABLE collection_chirps (
+	collection_id UUID NOT NULL,
+	CONSTRAINT fk_collection_id
+	FOREIGN KEY (collection_id)
+	REFERENCES collections(id)
+	ON DELETE CASCADE,
+	chirp_id UUID NOT NULL,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (collection_id, chirp_id)
+);
+
+CREATE INDEX collection_chirps_saved_idx ON collection_chirps (collection_id, created_at DESC, chirp_id DESC);
+
+-- +goose Down
+DROP TABLE collection_chirps;
+DROP TABLE collections;
+DROP TABLE bookmarks;
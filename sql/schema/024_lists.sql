@@ -0,0 +1,54 @@
+-- +goose Up
+CREATE TABLE lists (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	is_private BOOLEAN NOT NULL DEFAULT FALSE,
+	UNIQUE (user_id, name)
+);
+
+CREATE TABLE list_members (
+	list_id UUID NOT NULL,
+	CONSTRAINT fk_list_id
+	FOREIGN KEY (list_id)
+	REFERENCES lists(id)
+	ON DELETE CASCADE,
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (list_id, user_id)
+);
+
+CREATE TABLE list_subscriptions (
+	list_id UUID NOT NULL,
+	CONSTRAINT fk_list_id
+	FOREIGN KEY (list_id)
+	REFERENCES lists(id)
+	ON DELETE CASCADE,
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (list_id, user_id)
+);
+
+CREATE INDEX list_subscriptions_user_id_idx ON list_subscriptions (user_id);
+CREATE INDEX chirps_user_created_idx ON chirps (user_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX chirps_user_created_idx;
+DROP TABLE list_subscriptions;
+DROP TABLE list_members;
+DROP TABLE lists;
@@ -0,0 +1,80 @@
+-- +goose Up
+CREATE TABLE follows (
+	follower_id UUID NOT NULL,
+	CONSTRAINT fk_follower_id
+	FOREIGN KEY (follower_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	followed_id UUID NOT NULL,
+	CONSTRAINT fk_followed_id
+	FOREIGN KEY (followed_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (follower_id, followed_id)
+);
+
+CREATE INDEX follows_followed_id_idx ON follows (followed_id);
+
+ALTER TABLE users
+ADD COLUMN dm_policy TEXT NOT NULL DEFAULT 'everyone';
+
+CREATE TABLE conversations (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	created_by UUID NOT NULL,
+	CONSTRAINT fk_created_by
+	FOREIGN KEY (created_by)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	is_group BOOLEAN NOT NULL,
+	-- Set for one-to-one conversations so there is only ever one per pair.
+	direct_key TEXT UNIQUE DEFAULT NULL
+);
+
+CREATE TABLE conversation_members (
+	conversation_id UUID NOT NULL,
+	CONSTRAINT fk_conversation_id
+	FOREIGN KEY (conversation_id)
+	REFERENCES conversations(id)
+	ON DELETE CASCADE,
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	joined_at TIMESTAMP NOT NULL,
+	last_read_at TIMESTAMP DEFAULT NULL,
+	PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX conversation_members_user_id_idx ON conversation_members (user_id);
+
+CREATE TABLE direct_messages (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	conversation_id UUID NOT NULL,
+	CONSTRAINT fk_conversation_id
+	FOREIGN KEY (conversation_id)
+	REFERENCES conversations(id)
+	ON DELETE CASCADE,
+	sender_id UUID NOT NULL,
+	CONSTRAINT fk_sender_id
+	FOREIGN KEY (sender_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	body TEXT NOT NULL
+);
+
+CREATE INDEX direct_messages_history_idx ON direct_messages (conversation_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE direct_messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
+
+ALTER TABLE users
+DROP COLUMN dm_policy;
+
+DROP TABLE follows;
@@ -0,0 +1,40 @@
+-- +goose Up
+CREATE TABLE notifications (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	type TEXT NOT NULL,
+	chirp_id UUID DEFAULT NULL,
+	CONSTRAINT fk_chirp_id
+	FOREIGN KEY (chirp_id)
+	REFERENCES chirps(id)
+	ON DELETE CASCADE,
+	-- Unread notifications with the same key are one notification with
+	-- several actors, most recent first.
+	group_key TEXT NOT NULL,
+	actor_ids UUID[] NOT NULL,
+	read_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE UNIQUE INDEX notifications_unread_group_idx ON notifications (user_id, group_key) WHERE read_at IS NULL;
+CREATE INDEX notifications_user_idx ON notifications (user_id, updated_at DESC, id DESC);
+
+CREATE TABLE notification_preferences (
+	user_id UUID NOT NULL,
+	CONSTRAINT fk_user_id
+	FOREIGN KEY (user_id)
+	REFERENCES users(id)
+	ON DELETE CASCADE,
+	type TEXT NOT NULL,
+	enabled BOOLEAN NOT NULL,
+	PRIMARY KEY (user_id, type)
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
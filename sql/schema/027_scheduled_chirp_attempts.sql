@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE scheduled_chirps
+ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN retry_at TIMESTAMP DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE scheduled_chirps
+DROP COLUMN retry_at,
+DROP COLUMN attempts;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE attachments
+ADD COLUMN processing_attempts INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN retry_at TIMESTAMP DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE attachments
+DROP COLUMN retry_at,
+DROP COLUMN processing_attempts;
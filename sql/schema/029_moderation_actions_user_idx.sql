@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX moderation_actions_user_id_idx ON moderation_actions (user_id, created_at) WHERE action = 'warn';
+
+-- +goose Down
+DROP INDEX moderation_actions_user_id_idx;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE webhook_outbox
+ADD COLUMN author_id UUID DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE webhook_outbox
+DROP COLUMN author_id;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE attachment_variants
+ADD COLUMN url_key UUID NOT NULL DEFAULT gen_random_uuid();
+
+-- +goose Down
+ALTER TABLE attachment_variants
+DROP COLUMN url_key;
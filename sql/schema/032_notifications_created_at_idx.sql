@@ -0,0 +1,7 @@
+-- +goose Up
+DROP INDEX notifications_user_idx;
+CREATE INDEX notifications_user_idx ON notifications (user_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX notifications_user_idx;
+CREATE INDEX notifications_user_idx ON notifications (user_id, updated_at DESC, id DESC);
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/pubsub"
+	"github.com/google/uuid"
+)
+
+// Event types pushed to streams.
+const (
+	eventChirp        = "chirp"
+	eventChirpEdited  = "chirp_edited"
+	eventChirpDeleted = "chirp_deleted"
+	eventNotification = "notification"
+	eventMessage      = "direct_message"
+	eventRead         = "conversation_read"
+	eventWarning      = "warning"
+	// eventResync tells the client it may have missed events and should
+	// reload over the REST API.
+	eventResync = pubsub.EventResync
+)
+
+// streamWriteTimeout bounds a single write to a stream, so a client that
+// stopped reading can't hold its handler open forever.
+const streamWriteTimeout = 10 * time.Second
+
+type streamChirpDeleted struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type streamRead struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	ReadAt         time.Time `json:"read_at"`
+}
+
+type streamNotification struct {
+	Type    string     `json:"type"`
+	ActorID uuid.UUID  `json:"actor_id"`
+	ChirpID *uuid.UUID `json:"chirp_id"`
+}
+
+type streamWarning struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	Note    string    `json:"note"`
+}
+
+// chirpTopics are the topics events about a chirp are published on: the
+// global feed, the author's own and the chirp's.
+func chirpTopics(chirp database.Chirp) []string {
+	return []string{"chirps", authorTopic(chirp.UserID), chirpTopic(chirp.ID)}
+}
+
+func chirpTopic(chirpID uuid.UUID) string {
+	return "chirp:" + chirpID.String()
+}
+
+func authorTopic(authorID uuid.UUID) string {
+	return "chirps:" + authorID.String()
+}
+
+func notificationTopic(userID uuid.UUID) string {
+	return "notifications:" + userID.String()
+}
+
+func messageTopic(userID uuid.UUID) string {
+	return "messages:" + userID.String()
+}
+
+// publishEvent pushes v to the subscribers of topics. Streams are best
+// effort, so an event that can't be encoded or relayed is logged and
+// dropped.
+func (cfg *apiConfig) publishEvent(topics []string, eventType string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Printf("stream: %v\n", err)
+		return
+	}
+	if err := cfg.broker.Publish(topics, eventType, data); err != nil {
+		fmt.Printf("stream: %v\n", err)
+	}
+}
+
+// waitForStreams waits for WebSocket connections to finish their closing
+// handshakes after shutdown, or for ctx to run out.
+func (cfg *apiConfig) waitForStreams(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		cfg.streamConns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+func (cfg *apiConfig) eventID(e pubsub.Event) string {
+	return cfg.broker.Epoch() + "-" + strconv.FormatUint(e.ID, 10)
+}
+
+// parseEventID reads a Last-Event-ID. IDs from before a restart belong to
+// another epoch and can't be resumed from.
+func (cfg *apiConfig) parseEventID(s string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(s, "-")
+	if !ok || epoch != cfg.broker.Epoch() {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// handlerGetStream pushes chirps, deletions and, for signed-in users, their
+// notifications as Server-Sent Events. ?feed= picks the chirps: global (the
+// default), author (with ?author_id=) or timeline (the viewer and the people
+// they follow).
+func (cfg *apiConfig) handlerGetStream(w http.ResponseWriter, r *http.Request) {
+	viewer, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	var topics []string
+	switch r.URL.Query().Get("feed") {
+	case "", "global":
+		topics = []string{"chirps"}
+	case "author":
+		authorID, err := uuid.Parse(r.URL.Query().Get("author_id"))
+		if err != nil {
+			returnError(w, fmt.Errorf("author feed needs an author_id"), 400)
+			return
+		}
+		topics = []string{authorTopic(authorID)}
+	case "timeline":
+		if !viewer.Valid {
+			returnError(w, fmt.Errorf("timeline feed needs a signed-in user"), 401)
+			return
+		}
+		follows, err := cfg.db.GetFollowing(r.Context(), viewer.UUID)
+		if err != nil {
+			returnError(w, err, 500)
+			return
+		}
+		topics = []string{authorTopic(viewer.UUID)}
+		for _, f := range follows {
+			topics = append(topics, authorTopic(f.FollowedID))
+		}
+	default:
+		returnError(w, fmt.Errorf("feed must be global, author or timeline"), 400)
+		return
+	}
+	if viewer.Valid {
+		topics = append(topics, notificationTopic(viewer.UUID))
+	}
+
+	// Like the REST timelines, blocks and mutes are applied as of when the
+	// stream opened.
+	filtered, err := cfg.filteredAuthors(r.Context(), viewer)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	// EventSource sends Last-Event-ID when it reconnects; the query
+	// parameter is for clients that can't set headers.
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var after uint64
+	complete := true
+	if lastEventID != "" {
+		after, complete = cfg.parseEventID(lastEventID)
+	}
+
+	sub, replayed := cfg.broker.Subscribe(topics, after)
+	defer cfg.broker.Unsubscribe(sub)
+	complete = complete && replayed
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+
+	write := func(s string) bool {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprint(w, s); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	if !complete && !write(fmt.Sprintf("event: %s\ndata: {}\n\n", eventResync)) {
+		return
+	}
+	if !write(": connected\n\n") {
+		return
+	}
+
+	heartbeat := time.NewTicker(cfg.streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cfg.streams.Done():
+			return
+		case <-heartbeat.C:
+			if !write(": ping\n\n") {
+				return
+			}
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind. EventSource reconnects on
+				// its own and resumes from the last event it got.
+				return
+			}
+			if !streamEventVisible(e, filtered) {
+				continue
+			}
+			if !write(fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", cfg.eventID(e), e.Type, e.Data)) {
+				return
+			}
+		}
+	}
+}
+
+// streamEventVisible hides chirp events from authors the viewer blocked,
+// was blocked by, or muted.
+func streamEventVisible(e pubsub.Event, filtered map[uuid.UUID]bool) bool {
+	if e.Type != eventChirp && e.Type != eventChirpEdited && e.Type != eventChirpDeleted {
+		return true
+	}
+	return !slices.ContainsFunc(e.Topics, func(topic string) bool {
+		id, ok := strings.CutPrefix(topic, "chirps:")
+		if !ok {
+			return false
+		}
+		authorID, err := uuid.Parse(id)
+		return err == nil && filtered[authorID]
+	})
+}
+
+// publishChirp pushes a new or edited chirp as anyone would see it, without
+// the author's own bookmark or vote state.
+func (cfg *apiConfig) publishChirp(ctx context.Context, eventType string, chirp database.Chirp) {
+	info, err := chirpInfoFor(ctx, cfg.db, uuid.NullUUID{}, chirp)
+	if err != nil {
+		fmt.Printf("stream: %v\n", err)
+		return
+	}
+	cfg.publishEvent(chirpTopics(chirp), eventType, info)
+}
+
+// publishToMembers pushes a conversation event about actorID to each
+// member's streams. Members with a block either way between them and the
+// actor are left out, as they are from the REST history of group
+// conversations.
+func (cfg *apiConfig) publishToMembers(ctx context.Context, actorID uuid.UUID, memberIDs []uuid.UUID, eventType string, v any) {
+	topics := make([]string, 0, len(memberIDs))
+	for _, id := range memberIDs {
+		if id != actorID {
+			blocked, err := cfg.blockedBetween(ctx, actorID, id)
+			if err != nil {
+				fmt.Printf("stream: %v\n", err)
+				return
+			}
+			if blocked {
+				continue
+			}
+		}
+		topics = append(topics, messageTopic(id))
+	}
+	cfg.publishEvent(topics, eventType, v)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	subscriptionStatusActive   = "active"
+	subscriptionStatusPastDue  = "past_due"
+	subscriptionStatusCanceled = "canceled"
+
+	defaultSubscriptionPlan   = "red"
+	defaultSubscriptionPeriod = time.Hour * 24 * 30
+)
+
+// migratedPeriodEnd is the period end migration 012 gave subscriptions that
+// predate billing periods.
+var migratedPeriodEnd = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// applySubscriptionEvent moves a user's subscription through its lifecycle in
+// response to a Polka event and re-derives is_chirpy_red from the result. It
+// returns false for event types it doesn't know about.
+func (cfg *apiConfig) applySubscriptionEvent(ctx context.Context, qtx *database.Queries, event database.WebhookEvent) (bool, error) {
+	params := polkaEvent{}
+	if err := json.Unmarshal(event.Payload, &params); err != nil {
+		return false, fmt.Errorf("%w: %v", errWebhookPermanent, err)
+	}
+	data := params.Data
+	userID := event.UserID.UUID
+	now := time.Now().UTC()
+
+	sub, err := qtx.GetSubscriptionForUser(ctx, userID)
+	hasSub := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	next := database.UpsertSubscriptionParams{
+		UserID:            userID,
+		Plan:              sub.Plan,
+		Status:            sub.Status,
+		CurrentPeriodEnd:  sub.CurrentPeriodEnd,
+		CancelAtPeriodEnd: sub.CancelAtPeriodEnd,
+		CanceledAt:        sub.CanceledAt,
+	}
+
+	var historyEvent string
+
+	switch event.EventType {
+	case "user.upgraded":
+		historyEvent = "upgraded"
+		next.Plan = data.Plan
+		if next.Plan == "" {
+			next.Plan = defaultSubscriptionPlan
+		}
+		next.Status = subscriptionStatusActive
+		next.CancelAtPeriodEnd = false
+		next.CanceledAt = sql.NullTime{}
+		if !data.CurrentPeriodEnd.IsZero() {
+			next.CurrentPeriodEnd = data.CurrentPeriodEnd.UTC()
+		} else if !hasSub || sub.CurrentPeriodEnd.Before(now) {
+			next.CurrentPeriodEnd = now.Add(defaultSubscriptionPeriod)
+		}
+
+	case "user.downgraded":
+		historyEvent = "downgraded"
+		if !hasSub {
+			return false, fmt.Errorf("%w: user %s has no subscription to downgrade", errWebhookPermanent, userID)
+		}
+		if data.Plan == "" || data.Plan == "free" {
+			// Downgrading to free keeps the paid plan until the period ends.
+			cancelAtPeriodEnd(&next, data.CurrentPeriodEnd, now)
+		} else {
+			next.Plan = data.Plan
+		}
+
+	case "subscription.renewed":
+		historyEvent = "renewed"
+		if !hasSub {
+			return false, fmt.Errorf("%w: user %s has no subscription to renew", errWebhookPermanent, userID)
+		}
+		next.Status = subscriptionStatusActive
+		next.CancelAtPeriodEnd = false
+		next.CanceledAt = sql.NullTime{}
+		if !data.CurrentPeriodEnd.IsZero() {
+			next.CurrentPeriodEnd = data.CurrentPeriodEnd.UTC()
+		} else {
+			next.CurrentPeriodEnd = sub.CurrentPeriodEnd.Add(defaultSubscriptionPeriod)
+		}
+
+	case "subscription.payment_failed":
+		historyEvent = "payment_failed"
+		if !hasSub {
+			return false, fmt.Errorf("%w: user %s has no subscription", errWebhookPermanent, userID)
+		}
+		next.Status = subscriptionStatusPastDue
+
+	case "subscription.canceled":
+		historyEvent = "canceled"
+		if !hasSub {
+			return false, fmt.Errorf("%w: user %s has no subscription to cancel", errWebhookPermanent, userID)
+		}
+		if data.Immediately {
+			next.Status = subscriptionStatusCanceled
+			next.CanceledAt = sql.NullTime{Time: now, Valid: true}
+			next.CurrentPeriodEnd = now
+		} else {
+			cancelAtPeriodEnd(&next, data.CurrentPeriodEnd, now)
+		}
+
+	case "subscription.refunded":
+		historyEvent = "refunded"
+		if !hasSub {
+			return false, fmt.Errorf("%w: user %s has no subscription to refund", errWebhookPermanent, userID)
+		}
+		next.Status = subscriptionStatusCanceled
+		next.CanceledAt = sql.NullTime{Time: now, Valid: true}
+		next.CurrentPeriodEnd = now
+
+	default:
+		return false, nil
+	}
+
+	sub, err = qtx.UpsertSubscription(ctx, next)
+	if err != nil {
+		return false, err
+	}
+
+	if err := recordSubscriptionChange(ctx, qtx, sub, historyEvent, sql.NullString{String: event.ID, Valid: true}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// cancelAtPeriodEnd sets next to end with its period. A migrated subscription
+// has no real period to wait for, so it ends at the period end Polka sent or,
+// failing that, now.
+func cancelAtPeriodEnd(next *database.UpsertSubscriptionParams, periodEnd, now time.Time) {
+	next.CancelAtPeriodEnd = true
+	if next.CurrentPeriodEnd.Before(migratedPeriodEnd) {
+		return
+	}
+	if !periodEnd.IsZero() {
+		next.CurrentPeriodEnd = periodEnd.UTC()
+	} else {
+		next.CurrentPeriodEnd = now
+	}
+}
+
+// subscriptionEntitled reports whether sub grants its plan at now. It doesn't
+// wait for the scheduler to catch up: a subscription set to cancel ends with
+// its period, and one that wasn't renewed ends once the grace period after it
+// is over.
+func subscriptionEntitled(sub database.Subscription, now time.Time, grace time.Duration) bool {
+	if sub.Status == subscriptionStatusCanceled {
+		return false
+	}
+	if now.Before(sub.CurrentPeriodEnd) {
+		return true
+	}
+	return !sub.CancelAtPeriodEnd && now.Before(sub.CurrentPeriodEnd.Add(grace))
+}
+
+// entitledSubscription returns the user's subscription if it currently
+// grants its plan.
+func (cfg *apiConfig) entitledSubscription(ctx context.Context, userID uuid.UUID) (database.Subscription, bool, error) {
+	sub, err := cfg.db.GetSubscriptionForUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Subscription{}, false, nil
+	}
+	if err != nil {
+		return database.Subscription{}, false, err
+	}
+	return sub, subscriptionEntitled(sub, time.Now().UTC(), cfg.subGrace), nil
+}
+
+// isChirpyRed derives is_chirpy_red from the user's subscription as of now,
+// rather than trusting the users column to have been synced since the period
+// ended.
+func (cfg *apiConfig) isChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	_, entitled, err := cfg.entitledSubscription(ctx, userID)
+	return entitled, err
+}
+
+// recordSubscriptionChange writes a history row and re-derives the user's
+// is_chirpy_red column. Every subscription change goes through here. Reads
+// use isChirpyRed, since the column lags behind until the scheduler runs.
+func recordSubscriptionChange(ctx context.Context, qtx *database.Queries, sub database.Subscription, event string, webhookEventID sql.NullString) error {
+	createSubscriptionHistoryArgs := database.CreateSubscriptionHistoryParams{
+		SubscriptionID:   sub.ID,
+		Event:            event,
+		Plan:             sub.Plan,
+		Status:           sub.Status,
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+		WebhookEventID:   webhookEventID,
+	}
+	if err := qtx.CreateSubscriptionHistory(ctx, createSubscriptionHistoryArgs); err != nil {
+		return err
+	}
+
+	return qtx.SyncUserIsChirpyRed(ctx, sub.UserID)
+}
+
+// expireSubscriptions moves subscriptions whose period ended without a renewal
+// to past_due, and cancels them once the grace period is over. The updates are
+// single statements so running this on several instances at once is safe.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	overdue, err := qtx.MarkOverdueSubscriptionsPastDue(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sub := range overdue {
+		if err := recordSubscriptionChange(ctx, qtx, sub, "past_due", sql.NullString{}); err != nil {
+			return err
+		}
+	}
+
+	lapsed, err := qtx.CancelLapsedSubscriptions(ctx, time.Now().UTC().Add(-cfg.subGrace))
+	if err != nil {
+		return err
+	}
+	for _, sub := range lapsed {
+		if err := recordSubscriptionChange(ctx, qtx, sub, "expired", sql.NullString{}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (cfg *apiConfig) runSubscriptionScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := cfg.expireSubscriptions(ctx); err != nil {
+			fmt.Printf("subscription expiry: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) handlerGetSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	sub, err := cfg.db.GetSubscriptionForUser(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		returnError(w, fmt.Errorf("no subscription"), 404)
+		return
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	history, err := cfg.db.GetSubscriptionHistory(r.Context(), sub.ID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	type historyEntry struct {
+		CreatedAt        time.Time `json:"created_at"`
+		Event            string    `json:"event"`
+		Plan             string    `json:"plan"`
+		Status           string    `json:"status"`
+		CurrentPeriodEnd time.Time `json:"current_period_end"`
+	}
+
+	type responseVals struct {
+		ID                uuid.UUID      `json:"id"`
+		Plan              string         `json:"plan"`
+		Status            string         `json:"status"`
+		CurrentPeriodEnd  time.Time      `json:"current_period_end"`
+		CancelAtPeriodEnd bool           `json:"cancel_at_period_end"`
+		History           []historyEntry `json:"history"`
+	}
+
+	responseData := responseVals{
+		ID:                sub.ID,
+		Plan:              sub.Plan,
+		Status:            sub.Status,
+		CurrentPeriodEnd:  sub.CurrentPeriodEnd,
+		CancelAtPeriodEnd: sub.CancelAtPeriodEnd,
+		History:           []historyEntry{},
+	}
+	for _, h := range history {
+		responseData.History = append(responseData.History, historyEntry{
+			CreatedAt:        h.CreatedAt,
+			Event:            h.Event,
+			Plan:             h.Plan,
+			Status:           h.Status,
+			CurrentPeriodEnd: h.CurrentPeriodEnd,
+		})
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
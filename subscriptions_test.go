@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/database"
+)
+
+func TestSubscriptionEntitled(t *testing.T) {
+	end := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	grace := 72 * time.Hour
+
+	tests := []struct {
+		name string
+		sub  database.Subscription
+		now  time.Time
+		want bool
+	}{
+		{"active in period", database.Subscription{Status: subscriptionStatusActive, CurrentPeriodEnd: end}, end.Add(-time.Hour), true},
+		{"active past period within grace", database.Subscription{Status: subscriptionStatusActive, CurrentPeriodEnd: end}, end.Add(time.Hour), true},
+		{"past due within grace", database.Subscription{Status: subscriptionStatusPastDue, CurrentPeriodEnd: end}, end.Add(grace - time.Minute), true},
+		{"past due after grace", database.Subscription{Status: subscriptionStatusPastDue, CurrentPeriodEnd: end}, end.Add(grace), false},
+		{"cancelling in period", database.Subscription{Status: subscriptionStatusActive, CurrentPeriodEnd: end, CancelAtPeriodEnd: true}, end.Add(-time.Hour), true},
+		{"cancelling past period", database.Subscription{Status: subscriptionStatusActive, CurrentPeriodEnd: end, CancelAtPeriodEnd: true}, end, false},
+		{"canceled", database.Subscription{Status: subscriptionStatusCanceled, CurrentPeriodEnd: end}, end.Add(-time.Hour), false},
+	}
+	for _, tt := range tests {
+		if got := subscriptionEntitled(tt.sub, tt.now, grace); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestCancelAtPeriodEnd(t *testing.T) {
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	sent := time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		periodEnd time.Time
+		sent      time.Time
+		want      time.Time
+	}{
+		{"billed period kept", end, sent, end},
+		{"migrated uses sent period end", migratedPeriodEnd, sent, sent},
+		{"migrated without period end ends now", migratedPeriodEnd, time.Time{}, now},
+	}
+	for _, tt := range tests {
+		next := database.UpsertSubscriptionParams{CurrentPeriodEnd: tt.periodEnd}
+		cancelAtPeriodEnd(&next, tt.sent, now)
+		if !next.CancelAtPeriodEnd {
+			t.Errorf("%s: expected cancel_at_period_end to be set", tt.name)
+		}
+		if !next.CurrentPeriodEnd.Equal(tt.want) {
+			t.Errorf("%s: expected period end %v, got %v", tt.name, tt.want, next.CurrentPeriodEnd)
+		}
+	}
+}
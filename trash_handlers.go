@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/database"
+	"github.com/MagnusTrier/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerGetTrash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	chirps, err := cfg.db.GetDeletedChirpsForUser(r.Context(), userID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	type trashedChirp struct {
+		chirpInfo
+		DeletedAt    time.Time `json:"deleted_at"`
+		RestoreUntil time.Time `json:"restore_until"`
+	}
+
+	// Chirps past the window but not yet purged can't be restored, so
+	// they're left out.
+	cutoff := time.Now().UTC().Add(-cfg.trashRetention)
+	responseData := []trashedChirp{}
+	for _, chirp := range chirps {
+		if !chirp.DeletedAt.Time.After(cutoff) {
+			continue
+		}
+		responseData = append(responseData, trashedChirp{
+			chirpInfo:    newChirpInfo(chirp),
+			DeletedAt:    chirp.DeletedAt.Time,
+			RestoreUntil: chirp.DeletedAt.Time.Add(cfg.trashRetention),
+		})
+	}
+
+	data, err := json.Marshal(responseData)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerPostChirpRestore(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWTScope(token, cfg.jwtSecret, auth.ScopeChirpsWrite)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	restoreChirpArgs := database.RestoreChirpParams{
+		ID:        chirpID,
+		UserID:    userID,
+		DeletedAt: sql.NullTime{Time: time.Now().UTC().Add(-cfg.trashRetention), Valid: true},
+	}
+	chirp, err := qtx.RestoreChirp(r.Context(), restoreChirpArgs)
+	if errors.Is(err, sql.ErrNoRows) {
+		returnError(w, fmt.Errorf("no restorable chirp in your trash"), 404)
+		return
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := enqueueOutboxEvent(r.Context(), qtx, webhooks.EventChirpRestored, chirp.UserID, newChirpInfo(chirp)); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	cfg.publishChirp(r.Context(), eventChirp, chirp)
+
+	info, err := chirpInfoFor(r.Context(), cfg.db, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+// runTrashPurger permanently deletes chirps that have been in the trash for
+// longer than trashRetention, along with their media.
+func (cfg *apiConfig) runTrashPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := sql.NullTime{Time: time.Now().UTC().Add(-cfg.trashRetention), Valid: true}
+		if n, err := cfg.purgeTrash(ctx, cutoff); err != nil {
+			fmt.Printf("trash purge: %v\n", err)
+		} else if n > 0 {
+			fmt.Printf("trash purge: removed %d chirps\n", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeTrash deletes the blobs of the purged chirps' attachments before the
+// rows that point at them, so a failed blob delete is retried on the next
+// run rather than leaving the blob behind. Running it from several instances
+// at once only deletes some blobs twice, which is harmless. Chirps with
+// reports still waiting on a moderator stay in the trash until those are
+// closed, since purging one would cascade away its reports.
+func (cfg *apiConfig) purgeTrash(ctx context.Context, cutoff sql.NullTime) (int64, error) {
+	attachments, err := cfg.db.GetAttachmentsOfPurgeableChirps(ctx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	if err := cfg.deleteAttachmentBlobs(ctx, attachments); err != nil {
+		return 0, err
+	}
+	return cfg.db.PurgeDeletedChirps(ctx, cutoff)
+}
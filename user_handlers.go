@@ -34,6 +34,25 @@ func returnError(w http.ResponseWriter, err error, code int) {
 	w.Write(data)
 }
 
+func returnValidationErrors(w http.ResponseWriter, violations []auth.PolicyViolation) {
+	w.WriteHeader(422)
+
+	type returnValsError struct {
+		Msg        string                 `json:"error"`
+		Violations []auth.PolicyViolation `json:"violations"`
+	}
+
+	data, err := json.Marshal(returnValsError{
+		Msg:        "Error: password does not meet the password policy",
+		Violations: violations,
+	})
+	if err != nil {
+		fmt.Print(err)
+		return
+	}
+	w.Write(data)
+}
+
 func (cfg *apiConfig) handlerPostUsers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -51,7 +70,12 @@ func (cfg *apiConfig) handlerPostUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hashed, err := auth.HashPassword(params.Password)
+	if violations := cfg.passwordPolicy.Validate(params.Password, params.Email); len(violations) > 0 {
+		returnValidationErrors(w, violations)
+		return
+	}
+
+	hashed, err := auth.HashPassword(params.Password, cfg.hashParams)
 	if err != nil {
 		returnError(w, err, 500)
 		return
@@ -102,7 +126,6 @@ func (cfg *apiConfig) handlerPostLogin(w http.ResponseWriter, r *http.Request) {
 		returnError(w, err, 500)
 		return
 	}
-	dur := time.Duration(60*60) * time.Second
 
 	user, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
 	if err != nil {
@@ -110,12 +133,37 @@ func (cfg *apiConfig) handlerPostLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	match, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	match, needsRehash, err := auth.CheckPasswordHash(params.Password, user.HashedPassword, cfg.hashParams)
 	if err != nil || !match {
 		returnError(w, fmt.Errorf("Incorrect email or password"), 401)
 		return
 	}
 
+	if needsRehash {
+		// A failed rehash shouldn't block the login, the old hash still works.
+		if hashed, err := auth.HashPassword(params.Password, cfg.hashParams); err != nil {
+			fmt.Print(err)
+		} else if err := cfg.db.SetUserHashedPassword(r.Context(), database.SetUserHashedPasswordParams{
+			ID:             user.ID,
+			HashedPassword: hashed,
+		}); err != nil {
+			fmt.Print(err)
+		}
+	}
+
+	cfg.writeLoginResponse(w, r, user)
+}
+
+// writeLoginResponse issues a JWT and refresh token for user and writes them
+// along with the user's public info.
+func (cfg *apiConfig) writeLoginResponse(w http.ResponseWriter, r *http.Request, user database.User) {
+	if isSuspended(user) {
+		returnSuspended(w, user)
+		return
+	}
+
+	dur := time.Duration(60*60) * time.Second
+
 	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, dur)
 	if err != nil {
 		returnError(w, err, 500)
@@ -138,6 +186,12 @@ func (cfg *apiConfig) handlerPostLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), user.ID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
 	type responseVals struct {
 		userPublicInfo
 		Token        string `json:"token"`
@@ -150,7 +204,7 @@ func (cfg *apiConfig) handlerPostLogin(w http.ResponseWriter, r *http.Request) {
 			CreatedAt:   user.CreatedAt,
 			UpdatedAt:   user.UpdatedAt,
 			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
+			IsChirpyRed: isChirpyRed,
 		},
 		Token:        token,
 		RefreshToken: refresh_token,
@@ -179,6 +233,11 @@ func (cfg *apiConfig) handlerPostRefresh(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if tokenInfo.ClientID.Valid {
+		returnError(w, fmt.Errorf("token was issued to a third-party client"), 401)
+		return
+	}
+
 	if tokenInfo.Expired {
 		if err := cfg.db.RevokeToken(r.Context(), token); err != nil {
 			returnError(w, err, 500)
@@ -188,6 +247,16 @@ func (cfg *apiConfig) handlerPostRefresh(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	user, err := cfg.db.GetUser(r.Context(), tokenInfo.UserID)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+	if isSuspended(user) {
+		returnSuspended(w, user)
+		return
+	}
+
 	jwtToken, err := auth.MakeJWT(tokenInfo.UserID, cfg.jwtSecret, time.Duration(60*60*time.Second))
 	if err != nil {
 		returnError(w, err, 500)
@@ -251,7 +320,12 @@ func (cfg *apiConfig) handlerPutUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hashed, err := auth.HashPassword(params.Password)
+	if violations := cfg.passwordPolicy.Validate(params.Password, params.Email); len(violations) > 0 {
+		returnValidationErrors(w, violations)
+		return
+	}
+
+	hashed, err := auth.HashPassword(params.Password, cfg.hashParams)
 	if err != nil {
 		returnError(w, err, 500)
 		return
@@ -269,12 +343,18 @@ func (cfg *apiConfig) handlerPutUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), user.ID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
 	responseData := userPublicInfo{
 		ID:          user.ID,
 		CreatedAt:   user.CreatedAt,
 		UpdatedAt:   user.UpdatedAt,
 		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+		IsChirpyRed: isChirpyRed,
 	}
 
 	data, err := json.Marshal(responseData)
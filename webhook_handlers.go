@@ -1,59 +1,307 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/MagnusTrier/chirpy/internal/auth"
 	"github.com/MagnusTrier/chirpy/internal/database"
 	"github.com/google/uuid"
 )
 
+const maxWebhookBodyBytes = 1 << 20
+
+const (
+	webhookStatusPending   = "pending"
+	webhookStatusProcessed = "processed"
+	webhookStatusSkipped   = "skipped"
+	webhookStatusFailed    = "failed"
+)
+
+// errWebhookPermanent marks failures that retrying the delivery won't fix,
+// like an event for a user that doesn't exist. Those are acknowledged to
+// Polka and left in the ledger for an admin to replay.
+var errWebhookPermanent = errors.New("permanent webhook failure")
+
+type polkaEvent struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      struct {
+		UserID           string    `json:"user_id"`
+		Plan             string    `json:"plan"`
+		CurrentPeriodEnd time.Time `json:"current_period_end"`
+		Immediately      bool      `json:"immediately"`
+	} `json:"data"`
+}
+
 func (cfg *apiConfig) handlerPostPolkaWebhooks(w http.ResponseWriter, r *http.Request) {
-	type requestVals struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
-	}
+	w.Header().Set("Content-Type", "application/json")
 
-	key, err := auth.GetAPIKey(r.Header)
+	defer r.Body.Close()
+
+	// The signature covers the exact bytes Polka sent, so read them before
+	// decoding anything.
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
 	if err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	timestamp := r.Header.Get("Polka-Timestamp")
+	if err := auth.VerifyWebhookSignature(
+		cfg.polkaSecrets,
+		timestamp,
+		r.Header.Get("Polka-Signature"),
+		body,
+		cfg.polkaTolerance,
+		time.Now(),
+	); err != nil {
 		returnError(w, err, 401)
 		return
 	}
 
-	if key != cfg.polkaKey {
-		returnError(w, fmt.Errorf("invalid api key"), 401)
+	params := polkaEvent{}
+	if err := json.Unmarshal(body, &params); err != nil {
+		returnError(w, err, 400)
+		return
+	}
+
+	// Deliveries without an id are deduplicated on their content.
+	eventID := params.ID
+	if eventID == "" {
+		sum := sha256.Sum256(body)
+		eventID = hex.EncodeToString(sum[:])
+	}
 
+	occurredAt := params.CreatedAt
+	if occurredAt.IsZero() {
+		ts, _ := strconv.ParseInt(timestamp, 10, 64)
+		occurredAt = time.Unix(ts, 0)
 	}
 
-	defer r.Body.Close()
+	userID := uuid.NullUUID{}
+	if id, err := uuid.Parse(params.Data.UserID); err == nil {
+		userID = uuid.NullUUID{UUID: id, Valid: true}
+	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := requestVals{}
-	if err := decoder.Decode(&params); err != nil {
+	createWebhookEventArgs := database.CreateWebhookEventParams{
+		ID:         eventID,
+		EventType:  params.Event,
+		UserID:     userID,
+		Payload:    body,
+		OccurredAt: occurredAt.UTC(),
+	}
+	event, err := cfg.db.CreateWebhookEvent(r.Context(), createWebhookEventArgs)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Seen before. Only redo the work if the earlier attempt didn't finish.
+		event, err = cfg.db.GetWebhookEvent(r.Context(), eventID)
+	}
+	if err != nil {
 		returnError(w, err, 500)
 		return
 	}
 
-	UserID, err := uuid.Parse(params.Data.UserID)
-	if err != nil {
+	if event.Status == webhookStatusProcessed || event.Status == webhookStatusSkipped {
+		w.WriteHeader(204)
+		return
+	}
+
+	if err := cfg.processWebhookEvent(r.Context(), eventID, false); err != nil && !errors.Is(err, errWebhookPermanent) {
 		returnError(w, err, 500)
 		return
 	}
 
-	if params.Event == "user.upgraded" {
-		setUserIsChirpyRedArgs := database.SetUserIsChirpyRedParams{
-			ID:          UserID,
-			IsChirpyRed: true,
+	w.WriteHeader(204)
+}
+
+// processWebhookEvent applies a ledger entry at most once. The row is locked
+// until its new status is committed so concurrent retries of the same
+// delivery wait for each other. Failures are recorded on the entry. An event
+// older than one already processed for the same user is skipped unless force
+// is set.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, eventID string, force bool) error {
+	err := cfg.applyWebhookEvent(ctx, eventID, force)
+	if err != nil {
+		setWebhookEventStatusArgs := database.SetWebhookEventStatusParams{
+			ID:        eventID,
+			Status:    webhookStatusFailed,
+			LastError: err.Error(),
+		}
+		if err := cfg.db.SetWebhookEventStatus(ctx, setWebhookEventStatusArgs); err != nil {
+			fmt.Print(err)
+		}
+	}
+	return err
+}
+
+func (cfg *apiConfig) applyWebhookEvent(ctx context.Context, eventID string, force bool) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	finish := func(status, reason string) error {
+		setWebhookEventStatusArgs := database.SetWebhookEventStatusParams{
+			ID:        eventID,
+			Status:    status,
+			LastError: reason,
 		}
-		if err := cfg.db.SetUserIsChirpyRed(r.Context(), setUserIsChirpyRedArgs); err != nil {
-			returnError(w, err, 404)
+		if err := qtx.SetWebhookEventStatus(ctx, setWebhookEventStatusArgs); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	event, err := qtx.LockWebhookEvent(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if event.Status == webhookStatusProcessed || event.Status == webhookStatusSkipped {
+		// Someone else got here first.
+		return nil
+	}
+
+	if !event.UserID.Valid {
+		return fmt.Errorf("%w: event has no valid user id", errWebhookPermanent)
+	}
+
+	if !force {
+		hasNewerProcessedWebhookEventArgs := database.HasNewerProcessedWebhookEventParams{
+			UserID:     event.UserID,
+			ID:         event.ID,
+			OccurredAt: event.OccurredAt,
+		}
+		newer, err := qtx.HasNewerProcessedWebhookEvent(ctx, hasNewerProcessedWebhookEventArgs)
+		if err != nil {
+			return err
+		}
+		if newer {
+			return finish(webhookStatusSkipped, "superseded by a newer event")
+		}
+	}
+
+	if _, err := qtx.GetUser(ctx, event.UserID.UUID); errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: user %s not found", errWebhookPermanent, event.UserID.UUID)
+	} else if err != nil {
+		return err
+	}
+
+	handled, err := cfg.applySubscriptionEvent(ctx, qtx, event)
+	if err != nil {
+		return err
+	}
+	if !handled {
+		return finish(webhookStatusSkipped, "unhandled event type")
+	}
+
+	return finish(webhookStatusProcessed, "")
+}
+
+func (cfg *apiConfig) handlerGetWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 500 {
+			returnError(w, fmt.Errorf("limit must be between 1 and 500"), 400)
 			return
 		}
+		limit = n
 	}
 
-	w.WriteHeader(204)
+	var events []database.WebhookEvent
+	var err error
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		getWebhookEventsByStatusArgs := database.GetWebhookEventsByStatusParams{
+			Status: status,
+			Limit:  int32(limit),
+		}
+		events, err = cfg.db.GetWebhookEventsByStatus(r.Context(), getWebhookEventsByStatusArgs)
+	} else {
+		events, err = cfg.db.GetRecentWebhookEvents(r.Context(), int32(limit))
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if events == nil {
+		events = []database.WebhookEvent{}
+	}
+
+	data, err := json.Marshal(events)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func (cfg *apiConfig) handlerPostWebhookEventReplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	eventID := r.PathValue("eventID")
+	event, err := cfg.db.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		returnError(w, err, 404)
+		return
+	}
+	if event.Status == webhookStatusProcessed {
+		returnError(w, fmt.Errorf("event was already processed"), 409)
+		return
+	}
+
+	// Replays are how a skipped or failed event is forced through, so reset
+	// it and apply it even if a newer event was processed since.
+	if err := cfg.db.ResetWebhookEvent(r.Context(), eventID); err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	if err := cfg.processWebhookEvent(r.Context(), eventID, true); errors.Is(err, errWebhookPermanent) {
+		returnError(w, err, 422)
+		return
+	} else if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	event, err = cfg.db.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(data)
 }
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MagnusTrier/chirpy/internal/auth"
+	"github.com/MagnusTrier/chirpy/internal/pubsub"
+	"github.com/MagnusTrier/chirpy/internal/websocket"
+	"github.com/google/uuid"
+)
+
+const (
+	wsMaxMessageSize    = 4096
+	wsMaxSubscriptions  = 20
+	wsSendQueueSize     = 64
+	wsPingInterval      = 30 * time.Second
+	wsPongWait          = 2 * wsPingInterval
+	wsWriteTimeout      = 10 * time.Second
+	wsCloseHandshakeMax = 2 * time.Second
+)
+
+// Messages from the client are {"type": "subscribe"|"unsubscribe",
+// "channel": ...} or {"type": "ping"}. An optional id is echoed back in the
+// reply.
+type wsClientMessage struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	ID      string `json:"id"`
+}
+
+type wsServerMessage struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Channel string          `json:"channel,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+type wsConn struct {
+	cfg      *apiConfig
+	ws       *websocket.Conn
+	userID   uuid.UUID
+	filtered map[uuid.UUID]bool
+
+	// send is the connection's outgoing queue, drained by writeLoop alone.
+	send chan []byte
+
+	mu   sync.Mutex
+	subs map[string]*pubsub.Subscription
+
+	closeOnce   sync.Once
+	done        chan struct{}
+	closeCode   int
+	closeReason string
+}
+
+// handlerWebSocket serves the real-time API over a WebSocket. Browsers can't
+// set headers on the handshake, so the token may also be passed as
+// ?access_token=.
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		returnError(w, fmt.Errorf("missing access token"), 401)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		returnError(w, err, 401)
+		return
+	}
+
+	filtered, err := cfg.filteredAuthors(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+
+	ws, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	ws.MaxMessageSize = wsMaxMessageSize
+
+	cfg.streamConns.Add(1)
+	defer cfg.streamConns.Done()
+
+	c := &wsConn{
+		cfg:      cfg,
+		ws:       ws,
+		userID:   userID,
+		filtered: filtered,
+		send:     make(chan []byte, wsSendQueueSize),
+		subs:     map[string]*pubsub.Subscription{},
+		done:     make(chan struct{}),
+	}
+	c.run()
+}
+
+func (c *wsConn) run() {
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		c.writeLoop()
+	}()
+
+	c.readLoop()
+
+	// The read side ended first if the client went away; make sure the
+	// writer stops too, then drop everything.
+	c.close(websocket.CloseNormal, "")
+	<-writerDone
+	c.mu.Lock()
+	for channel, sub := range c.subs {
+		c.cfg.broker.Unsubscribe(sub)
+		delete(c.subs, channel)
+	}
+	c.mu.Unlock()
+	c.ws.Close()
+}
+
+// close ends the connection with a close frame carrying code. Only the first
+// call counts.
+func (c *wsConn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.done)
+	})
+}
+
+func (c *wsConn) readLoop() {
+	c.ws.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.ws.PongHandler = func() {
+		c.ws.SetReadDeadline(time.Now().Add(wsPongWait))
+	}
+
+	for {
+		opcode, data, err := c.ws.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				c.close(closeErr.Code, "")
+			}
+			return
+		}
+		c.ws.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		if opcode != websocket.OpText {
+			c.close(websocket.CloseUnsupportedData, "messages must be json text")
+			continue
+		}
+
+		var msg wsClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.reply(wsServerMessage{Type: "error", Error: "invalid json"})
+			continue
+		}
+
+		// Once closing, keep reading only to see the client's close frame.
+		select {
+		case <-c.done:
+			continue
+		default:
+		}
+		c.handle(msg)
+	}
+}
+
+func (c *wsConn) writeLoop() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	shutdown := c.cfg.streams.Done()
+
+	for {
+		select {
+		case data := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.ws.WriteMessage(websocket.OpText, data); err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				c.ws.SetReadDeadline(time.Now())
+				return
+			}
+		case <-ping.C:
+			c.ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.ws.WritePing(nil); err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				c.ws.SetReadDeadline(time.Now())
+				return
+			}
+		case <-shutdown:
+			shutdown = nil
+			c.close(websocket.CloseGoingAway, "server shutting down")
+		case <-c.done:
+			// Send the close frame and give the client a moment to answer
+			// it before the read side gives up.
+			c.ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			c.ws.WriteClose(c.closeCode, c.closeReason)
+			c.ws.SetReadDeadline(time.Now().Add(wsCloseHandshakeMax))
+			return
+		}
+	}
+}
+
+// reply queues a message for the client. A client whose queue is full isn't
+// keeping up, so it's disconnected rather than letting memory grow.
+func (c *wsConn) reply(msg wsServerMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Printf("websocket: %v\n", err)
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		c.close(websocket.CloseTryAgainLater, "send queue full")
+	}
+}
+
+func (c *wsConn) handle(msg wsClientMessage) {
+	switch msg.Type {
+	case "ping":
+		c.reply(wsServerMessage{Type: "pong", ID: msg.ID})
+	case "subscribe":
+		if err := c.subscribe(msg.Channel); err != nil {
+			c.reply(wsServerMessage{Type: "error", ID: msg.ID, Channel: msg.Channel, Error: err.Error()})
+			return
+		}
+		c.reply(wsServerMessage{Type: "subscribed", ID: msg.ID, Channel: msg.Channel})
+	case "unsubscribe":
+		c.mu.Lock()
+		if sub, ok := c.subs[msg.Channel]; ok {
+			c.cfg.broker.Unsubscribe(sub)
+			delete(c.subs, msg.Channel)
+		}
+		c.mu.Unlock()
+		c.reply(wsServerMessage{Type: "unsubscribed", ID: msg.ID, Channel: msg.Channel})
+	default:
+		c.reply(wsServerMessage{Type: "error", ID: msg.ID, Error: fmt.Sprintf("unknown message type %q", msg.Type)})
+	}
+}
+
+// channelTopics maps a channel name to broker topics:
+//
+//	timeline       chirps by the user and the people they follow
+//	chirp:<id>     edits and deletion of one chirp
+//	notifications  the user's notifications
+//	messages       direct messages and read receipts in the user's conversations
+func (c *wsConn) channelTopics(channel string) ([]string, error) {
+	ctx := c.cfg.streams
+
+	switch channel {
+	case "timeline":
+		follows, err := c.cfg.db.GetFollowing(ctx, c.userID)
+		if err != nil {
+			return nil, err
+		}
+		topics := []string{authorTopic(c.userID)}
+		for _, f := range follows {
+			topics = append(topics, authorTopic(f.FollowedID))
+		}
+		return topics, nil
+	case "notifications":
+		return []string{notificationTopic(c.userID)}, nil
+	case "messages":
+		return []string{messageTopic(c.userID)}, nil
+	}
+
+	if id, ok := strings.CutPrefix(channel, "chirp:"); ok {
+		chirpID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("chirp not found")
+		}
+		chirp, err := c.cfg.db.GetChirp(ctx, chirpID)
+		if err != nil || chirp.HiddenAt.Valid || chirp.DeletedAt.Valid {
+			return nil, fmt.Errorf("chirp not found")
+		}
+		blocked, err := c.cfg.blockedBetween(ctx, c.userID, chirp.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, fmt.Errorf("chirp not found")
+		}
+		return []string{chirpTopic(chirpID)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown channel %q", channel)
+}
+
+func (c *wsConn) subscribe(channel string) error {
+	c.mu.Lock()
+	_, subscribed := c.subs[channel]
+	full := len(c.subs) >= wsMaxSubscriptions
+	c.mu.Unlock()
+	if subscribed {
+		return nil
+	}
+	if full {
+		return fmt.Errorf("at most %d subscriptions per connection", wsMaxSubscriptions)
+	}
+
+	topics, err := c.channelTopics(channel)
+	if err != nil {
+		return err
+	}
+
+	sub, _ := c.cfg.broker.Subscribe(topics, 0)
+	c.mu.Lock()
+	if _, ok := c.subs[channel]; ok {
+		c.mu.Unlock()
+		c.cfg.broker.Unsubscribe(sub)
+		return nil
+	}
+	c.subs[channel] = sub
+	c.mu.Unlock()
+
+	go c.forward(channel, sub)
+	return nil
+}
+
+// forward copies a subscription's events into the send queue until it is
+// unsubscribed or the broker drops it for falling behind.
+func (c *wsConn) forward(channel string, sub *pubsub.Subscription) {
+	for e := range sub.C {
+		if !streamEventVisible(e, c.filtered) {
+			continue
+		}
+		c.reply(wsServerMessage{Type: "event", Channel: channel, Event: e.Type, Data: e.Data})
+	}
+	if sub.Err() != nil {
+		c.close(websocket.CloseTryAgainLater, "fell behind")
+	}
+}